@@ -18,15 +18,32 @@ package main
 
 import (
 	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/signals"
+	"knative.dev/pkg/webhook"
+	"knative.dev/pkg/webhook/certificates"
 
 	"github.com/infernus01/knative-demo/pkg/reconciler/ttlreaper"
 
+	_ "github.com/infernus01/knative-demo/pkg/client/injection/client"
 	_ "github.com/infernus01/knative-demo/pkg/client/injection/informers/clusterops/v1alpha1/ttlreaper"
 	_ "github.com/infernus01/knative-demo/pkg/client/injection/informers/factory"
+	_ "knative.dev/pkg/client/injection/apiextensions/informers/apiextensions/v1/customresourcedefinition"
 	_ "knative.dev/pkg/client/injection/kube/client"
+	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/namespace"
 	_ "knative.dev/pkg/injection/clients/dynamicclient"
 )
 
 func main() {
-	sharedmain.Main("ttlreaper-controller", ttlreaper.NewController)
+	ctx := webhook.WithOptions(signals.NewContext(), webhook.Options{
+		ServiceName: webhook.NameFromEnv(),
+		Port:        webhook.PortFromEnv(8443),
+		SecretName:  webhook.SecretNameFromEnv("ttlreaper-webhook-certs"),
+	})
+
+	sharedmain.MainWithContext(ctx, "ttlreaper-controller",
+		certificates.NewController,
+		newDefaultingAdmissionController,
+		newValidationAdmissionController,
+		ttlreaper.NewController,
+	)
 }
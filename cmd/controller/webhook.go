@@ -0,0 +1,73 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/webhook/resourcesemantics"
+	"knative.dev/pkg/webhook/resourcesemantics/defaulting"
+	"knative.dev/pkg/webhook/resourcesemantics/validation"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+// types are the resources the admission webhooks default and validate.
+var types = map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
+	v1alpha1.SchemeGroupVersion.WithKind("TTLReaper"): &v1alpha1.TTLReaper{},
+}
+
+func newDefaultingAdmissionController(ctx context.Context, _ configmap.Watcher) *controller.Impl {
+	return defaulting.NewAdmissionController(ctx,
+		// Name of the MutatingWebhookConfiguration
+		"defaulting.webhook.ttlreaper.clusterops.io",
+
+		// The path on which to serve the webhook
+		"/defaulting",
+
+		// The resources to default
+		types,
+
+		// A function that infuses the context passed to SetDefaults with custom metadata
+		func(ctx context.Context) context.Context { return ctx },
+
+		// Whether to disallow unknown fields
+		true,
+	)
+}
+
+func newValidationAdmissionController(ctx context.Context, _ configmap.Watcher) *controller.Impl {
+	return validation.NewAdmissionController(ctx,
+		// Name of the ValidatingWebhookConfiguration
+		"validation.webhook.ttlreaper.clusterops.io",
+
+		// The path on which to serve the webhook
+		"/resource-validation",
+
+		// The resources to validate
+		types,
+
+		// A function that infuses the context passed to Validate with custom metadata
+		func(ctx context.Context) context.Context { return ctx },
+
+		// Whether to disallow unknown fields
+		true,
+	)
+}
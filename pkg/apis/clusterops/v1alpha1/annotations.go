@@ -0,0 +1,23 @@
+package v1alpha1
+
+// Annotations that owners of target objects can set to control how the
+// reaper treats those objects, whatever the TTLReaper says
+const (
+	// KeepAnnotation set to "true" protects the object from the reaper
+	KeepAnnotation = "ttl.clusterops.io/keep"
+
+	// TTLAnnotation overrides the TTL of the object. It is either a number of
+	// seconds or a Go duration such as "72h", and is capped at maxTTL.
+	TTLAnnotation = "ttl.clusterops.io/ttl"
+
+	// ExpiresAtAnnotation is an RFC 3339 time at which the object is deleted,
+	// finished or not. It replaces the object's TTL.
+	ExpiresAtAnnotation = "ttl.clusterops.io/expires-at"
+)
+
+// FinishObservedAtAnnotation is set by the controller, not by owners, on
+// finished objects that don't record when they finished, when configured
+// to. It holds the RFC 3339 time the controller first observed them
+// finished, which their TTL counts from under the FirstObserved
+// missingFinishTime policy.
+const FinishObservedAtAnnotation = "ttl.clusterops.io/finish-observed-at"
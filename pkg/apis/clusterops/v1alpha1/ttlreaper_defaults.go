@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+
+	"knative.dev/pkg/apis"
+)
+
+var _ apis.Defaultable = (*TTLReaper)(nil)
+
+// SetDefaults implements apis.Defaultable
+func (r *TTLReaper) SetDefaults(ctx context.Context) {
+	r.Spec.SetDefaults(apis.WithinSpec(ctx))
+}
+
+// SetDefaults fills in the optional fields of the spec. A spec using the
+// single-target fields gets them, defaulted, as its only entry of Targets
+// when it has none. Validation keeps the two in agreement from then on.
+func (s *TTLReaperSpec) SetDefaults(ctx context.Context) {
+	if s.HasSingleTarget() {
+		s.TargetSettings.SetDefaults(ctx)
+		if len(s.Targets) == 0 {
+			s.Targets = []TargetSpec{s.SingleTarget()}
+		}
+	}
+
+	for i := range s.Targets {
+		s.Targets[i].SetDefaults(ctx)
+	}
+}
+
+// SetDefaults fills in the name and the optional settings of the target
+func (t *TargetSpec) SetDefaults(ctx context.Context) {
+	if t.Name == "" {
+		t.Name = strings.ToLower(t.Kind)
+	}
+	t.TargetSettings.SetDefaults(ctx)
+}
+
+// SetDefaults fills in the optional settings
+func (s *TargetSettings) SetDefaults(ctx context.Context) {
+	// An empty selector matches everything, same as no selector at all
+	if s.LabelSelector != nil && len(s.LabelSelector.MatchLabels) == 0 && len(s.LabelSelector.MatchExpressions) == 0 {
+		s.LabelSelector = nil
+	}
+	if s.NamespaceSelector != nil && len(s.NamespaceSelector.MatchLabels) == 0 && len(s.NamespaceSelector.MatchExpressions) == 0 {
+		s.NamespaceSelector = nil
+	}
+
+	if s.TTLFieldPath == "" {
+		s.TTLFieldPath = DefaultTTLFieldPath
+	}
+
+	if len(s.FinishTimeSources) == 0 {
+		s.FinishTimeSources = DefaultFinishTimeSources()
+	}
+	if s.MissingFinishTime == "" {
+		s.MissingFinishTime = MissingFinishTimeFirstObserved
+	}
+}
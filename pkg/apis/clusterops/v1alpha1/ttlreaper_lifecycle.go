@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"knative.dev/pkg/apis"
+)
+
+const (
+	// TTLReaperConditionReady is set when the reaper resolved its target,
+	// its policy is valid and it is scheduling deletions
+	TTLReaperConditionReady = apis.ConditionReady
+
+	// TTLReaperConditionTargetResolved is set when the target kind could be
+	// mapped to a resource served by the API server
+	TTLReaperConditionTargetResolved apis.ConditionType = "TargetResolved"
+
+	// TTLReaperConditionPolicyValid is set when the spec is valid and its
+	// field paths and CEL expressions compiled
+	TTLReaperConditionPolicyValid apis.ConditionType = "PolicyValid"
+
+	// TTLReaperConditionDegraded is True when the last reconcile could not
+	// process every namespace or object it was asked to
+	TTLReaperConditionDegraded apis.ConditionType = "Degraded"
+)
+
+var ttlReaperCondSet = apis.NewLivingConditionSet(
+	TTLReaperConditionTargetResolved,
+	TTLReaperConditionPolicyValid,
+)
+
+// GetConditionSet retrieves the condition set for this resource
+func (*TTLReaper) GetConditionSet() apis.ConditionSet {
+	return ttlReaperCondSet
+}
+
+// GetGroupVersionKind returns the GroupVersionKind of TTLReaper
+func (*TTLReaper) GetGroupVersionKind() schema.GroupVersionKind {
+	return SchemeGroupVersion.WithKind("TTLReaper")
+}
+
+// InitializeConditions sets the conditions that are not yet set to Unknown
+func (rs *TTLReaperStatus) InitializeConditions() {
+	ttlReaperCondSet.Manage(rs).InitializeConditions()
+}
+
+// IsReady returns true if the Ready condition is True
+func (rs *TTLReaperStatus) IsReady() bool {
+	return ttlReaperCondSet.Manage(rs).IsHappy()
+}
+
+// MarkTargetResolved records that the target kind was resolved
+func (rs *TTLReaperStatus) MarkTargetResolved(messageFormat string, messageA ...interface{}) {
+	ttlReaperCondSet.Manage(rs).MarkTrueWithReason(TTLReaperConditionTargetResolved, "Resolved", messageFormat, messageA...)
+}
+
+// MarkTargetNotResolved records that the target kind could not be resolved
+func (rs *TTLReaperStatus) MarkTargetNotResolved(reason, messageFormat string, messageA ...interface{}) {
+	ttlReaperCondSet.Manage(rs).MarkFalse(TTLReaperConditionTargetResolved, reason, messageFormat, messageA...)
+}
+
+// MarkPolicyValid records that the spec is valid and compiled
+func (rs *TTLReaperStatus) MarkPolicyValid() {
+	ttlReaperCondSet.Manage(rs).MarkTrue(TTLReaperConditionPolicyValid)
+}
+
+// MarkPolicyInvalid records that the spec is invalid or failed to compile
+func (rs *TTLReaperStatus) MarkPolicyInvalid(reason, messageFormat string, messageA ...interface{}) {
+	ttlReaperCondSet.Manage(rs).MarkFalse(TTLReaperConditionPolicyValid, reason, messageFormat, messageA...)
+}
+
+// MarkDegraded records that the last reconcile only partially succeeded
+func (rs *TTLReaperStatus) MarkDegraded(reason, messageFormat string, messageA ...interface{}) {
+	ttlReaperCondSet.Manage(rs).MarkTrueWithReason(TTLReaperConditionDegraded, reason, messageFormat, messageA...)
+}
+
+// MarkNotDegraded records that the last reconcile fully succeeded
+func (rs *TTLReaperStatus) MarkNotDegraded() {
+	ttlReaperCondSet.Manage(rs).MarkFalse(TTLReaperConditionDegraded, "AsExpected", "")
+}
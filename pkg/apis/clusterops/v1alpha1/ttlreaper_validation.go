@@ -0,0 +1,266 @@
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"knative.dev/pkg/apis"
+
+	"github.com/infernus01/knative-demo/pkg/celexpr"
+	"github.com/infernus01/knative-demo/pkg/fieldpath"
+)
+
+var _ apis.Validatable = (*TTLReaper)(nil)
+
+// Validate implements apis.Validatable
+func (r *TTLReaper) Validate(ctx context.Context) *apis.FieldError {
+	return r.Spec.Validate(apis.WithinSpec(ctx)).ViaField("spec")
+}
+
+// Validate checks that the spec describes targets the controller can work with
+func (s *TTLReaperSpec) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if s.HasSingleTarget() {
+		errs = errs.Also(validateKind(s.TargetKind, s.TargetAPIVersion, "targetKind", "targetAPIVersion"))
+		errs = errs.Also(s.TargetSettings.Validate(ctx))
+
+		// Defaulting copies the single-target fields into targets; anything
+		// else there means both forms were used at once, or that one was
+		// changed without the other. The name is the one thing users may
+		// pick for it.
+		if len(s.Targets) == 1 {
+			want := s.SingleTarget()
+			want.Name = s.Targets[0].Name
+			want.SetDefaults(ctx)
+			if !equality.Semantic.DeepEqual(s.Targets[0], want) {
+				errs = errs.Also(apis.ErrGeneric("targets[0] must match targetKind and the settings next to it; change both, or drop targetKind and its settings to only use targets",
+					"targetKind", "targets[0]"))
+			}
+		} else if len(s.Targets) > 1 {
+			errs = errs.Also(apis.ErrMultipleOneOf("targetKind", "targets"))
+		}
+		return errs
+	}
+
+	if len(s.Targets) == 0 {
+		return errs.Also(apis.ErrMissingOneOf("targetKind", "targets"))
+	}
+	if !equality.Semantic.DeepEqual(s.TargetSettings, TargetSettings{}) {
+		errs = errs.Also(apis.ErrGeneric("settings outside of targets only apply together with targetKind; move them into each target", "targets"))
+	}
+	names := make(map[string]struct{}, len(s.Targets))
+	for i := range s.Targets {
+		t := &s.Targets[i]
+		errs = errs.Also(t.Validate(ctx).ViaFieldIndex("targets", i))
+		if t.Name == "" {
+			continue
+		}
+		if _, dup := names[t.Name]; dup {
+			errs = errs.Also(apis.ErrInvalidValue(t.Name, "name", "must be unique among targets").ViaFieldIndex("targets", i))
+		}
+		names[t.Name] = struct{}{}
+	}
+	return errs
+}
+
+// Validate checks the name, the kind and the settings of the target
+func (t *TargetSpec) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if t.Name == "" {
+		errs = errs.Also(apis.ErrMissingField("name"))
+	} else if msgs := validation.IsDNS1123Label(t.Name); len(msgs) > 0 {
+		errs = errs.Also(apis.ErrInvalidValue(t.Name, "name", msgs...))
+	}
+	errs = errs.Also(validateKind(t.Kind, t.APIVersion, "kind", "apiVersion"))
+	return errs.Also(t.TargetSettings.Validate(ctx))
+}
+
+// validateKind checks a kind and apiVersion pair, reporting errors against
+// the given field names
+func validateKind(kind, apiVersion, kindField, apiVersionField string) (errs *apis.FieldError) {
+	if kind == "" {
+		errs = errs.Also(apis.ErrMissingField(kindField))
+	} else if strings.ContainsAny(kind, "/. ") {
+		errs = errs.Also(apis.ErrInvalidValue(kind, kindField, "must be a bare kind such as PipelineRun"))
+	}
+
+	if apiVersion == "" {
+		errs = errs.Also(apis.ErrMissingField(apiVersionField))
+	} else if gv, err := schema.ParseGroupVersion(apiVersion); err != nil {
+		errs = errs.Also(apis.ErrInvalidValue(apiVersion, apiVersionField, err.Error()))
+	} else if gv.Version == "" {
+		errs = errs.Also(apis.ErrInvalidValue(apiVersion, apiVersionField, "must include a version, e.g. batch/v1"))
+	}
+	return errs
+}
+
+// Validate checks the namespaces, selectors, expressions and TTLs
+func (s *TargetSettings) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if s.TargetNamespace != "" {
+		if msgs := validation.IsDNS1123Label(s.TargetNamespace); len(msgs) > 0 {
+			errs = errs.Also(apis.ErrInvalidValue(s.TargetNamespace, "targetNamespace", msgs...))
+		} else if IsSystemNamespace(s.TargetNamespace) && !s.IncludesSystemNamespace(s.TargetNamespace) {
+			errs = errs.Also(apis.ErrInvalidValue(s.TargetNamespace, "targetNamespace",
+				"is a system namespace; list it in includedSystemNamespaces to reap there"))
+		}
+		if s.NamespaceSelector != nil {
+			errs = errs.Also(apis.ErrMultipleOneOf("targetNamespace", "namespaceSelector"))
+		}
+		if len(s.ExcludedNamespaces) > 0 {
+			errs = errs.Also(apis.ErrMultipleOneOf("targetNamespace", "excludedNamespaces"))
+		}
+	}
+
+	if s.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(s.NamespaceSelector); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(metav1.FormatLabelSelector(s.NamespaceSelector), "namespaceSelector", err.Error()))
+		}
+	}
+	for i, ns := range s.ExcludedNamespaces {
+		if msgs := validation.IsDNS1123Label(ns); len(msgs) > 0 {
+			errs = errs.Also(apis.ErrInvalidValue(ns, apis.CurrentField, msgs...).ViaFieldIndex("excludedNamespaces", i))
+		}
+	}
+	for i, ns := range s.IncludedSystemNamespaces {
+		if !IsSystemNamespace(ns) {
+			errs = errs.Also(apis.ErrInvalidValue(ns, apis.CurrentField, "must be one of "+strings.Join(SystemNamespaces, ", ")).
+				ViaFieldIndex("includedSystemNamespaces", i))
+		}
+	}
+
+	if s.LabelSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(s.LabelSelector); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(metav1.FormatLabelSelector(s.LabelSelector), "labelSelector", err.Error()))
+		}
+	}
+
+	if s.TTLFieldPath != "" {
+		if _, err := fieldpath.Parse(s.TTLFieldPath); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(s.TTLFieldPath, "ttlFieldPath", err.Error()))
+		}
+	}
+
+	if s.FinishedWhen != "" {
+		if _, err := celexpr.Compile(s.FinishedWhen, cel.BoolType); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(s.FinishedWhen, "finishedWhen", err.Error()))
+		}
+	}
+
+	if s.Outcomes != nil {
+		errs = errs.Also(s.Outcomes.Validate(ctx).ViaField("outcomes"))
+	}
+
+	for i, source := range s.FinishTimeSources {
+		errs = errs.Also(source.Validate(ctx).ViaFieldIndex("finishTimeSources", i))
+	}
+
+	switch s.MissingFinishTime {
+	case "", MissingFinishTimeSkip, MissingFinishTimeFirstObserved, MissingFinishTimeCreationTime:
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(s.MissingFinishTime, "missingFinishTime",
+			"must be one of Skip, FirstObserved or CreationTime"))
+	}
+
+	if s.Retention != nil {
+		errs = errs.Also(s.Retention.Validate(ctx).ViaField("retention"))
+	}
+
+	if s.MaxAge != nil && s.MaxAge.Age.Duration <= 0 {
+		errs = errs.Also(apis.ErrInvalidValue(s.MaxAge.Age.Duration.String(), "maxAge.age", "must be positive"))
+	}
+
+	if s.MaxTTL != nil && s.MaxTTL.Duration < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(s.MaxTTL.Duration.String(), "maxTTL", "must not be negative"))
+	}
+	for _, f := range []struct {
+		name string
+		ttl  *metav1.Duration
+	}{
+		{"defaultTTL", s.DefaultTTL},
+		{"ttlAfterSuccess", s.TTLAfterSuccess},
+		{"ttlAfterFailure", s.TTLAfterFailure},
+		{"ttlAfterCompletion", s.TTLAfterCompletion},
+	} {
+		if f.ttl == nil {
+			continue
+		}
+		if f.ttl.Duration < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(f.ttl.Duration.String(), f.name, "must not be negative"))
+		} else if s.MaxTTL != nil && f.ttl.Duration > s.MaxTTL.Duration {
+			errs = errs.Also(apis.ErrGeneric(f.name+" must not exceed maxTTL", f.name, "maxTTL"))
+		}
+	}
+
+	return errs
+}
+
+// Validate checks that the expressions compile to bools
+func (o *OutcomeRules) Validate(ctx context.Context) (errs *apis.FieldError) {
+	for _, f := range []struct {
+		name       string
+		expression string
+	}{
+		{"succeededWhen", o.SucceededWhen},
+		{"failedWhen", o.FailedWhen},
+		{"cancelledWhen", o.CancelledWhen},
+	} {
+		if f.expression == "" {
+			continue
+		}
+		if _, err := celexpr.Compile(f.expression, cel.BoolType); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(f.expression, f.name, err.Error()))
+		}
+	}
+	return errs
+}
+
+// Validate checks the grouping and that at least one limit is set
+func (r *RetentionSpec) Validate(ctx context.Context) (errs *apis.FieldError) {
+	g := r.GroupBy
+	switch {
+	case g.Label == "" && g.OwnerKind == "":
+		errs = errs.Also(apis.ErrMissingOneOf("label", "ownerKind").ViaField("groupBy"))
+	case g.Label != "" && g.OwnerKind != "":
+		errs = errs.Also(apis.ErrMultipleOneOf("label", "ownerKind").ViaField("groupBy"))
+	case g.Label != "":
+		if msgs := validation.IsQualifiedName(g.Label); len(msgs) > 0 {
+			errs = errs.Also(apis.ErrInvalidValue(g.Label, "groupBy.label", msgs...))
+		}
+	case strings.ContainsAny(g.OwnerKind, "/. "):
+		errs = errs.Also(apis.ErrInvalidValue(g.OwnerKind, "groupBy.ownerKind", "must be a bare kind such as CronJob"))
+	}
+
+	if r.SuccessfulHistoryLimit == nil && r.FailedHistoryLimit == nil {
+		errs = errs.Also(apis.ErrGeneric("expected at least one of successfulHistoryLimit and failedHistoryLimit",
+			"successfulHistoryLimit", "failedHistoryLimit"))
+	}
+	if r.SuccessfulHistoryLimit != nil && *r.SuccessfulHistoryLimit < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*r.SuccessfulHistoryLimit, "successfulHistoryLimit", "must not be negative"))
+	}
+	if r.FailedHistoryLimit != nil && *r.FailedHistoryLimit < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*r.FailedHistoryLimit, "failedHistoryLimit", "must not be negative"))
+	}
+	return errs
+}
+
+// Validate checks that exactly one of the fields is set and that it compiles
+func (s *FinishTimeSource) Validate(ctx context.Context) (errs *apis.FieldError) {
+	switch {
+	case s.FieldPath == "" && s.Expression == "":
+		return apis.ErrMissingOneOf("fieldPath", "expression")
+	case s.FieldPath != "" && s.Expression != "":
+		return apis.ErrMultipleOneOf("fieldPath", "expression")
+	case s.FieldPath != "":
+		if _, err := fieldpath.Parse(s.FieldPath); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(s.FieldPath, "fieldPath", err.Error()))
+		}
+	default:
+		if _, err := celexpr.Compile(s.Expression, nil); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(s.Expression, "expression", err.Error()))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,285 @@
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTTLReaperSpecValidate(t *testing.T) {
+	hour := &metav1.Duration{Duration: time.Hour}
+	day := &metav1.Duration{Duration: 24 * time.Hour}
+	pipelineRuns := TargetSpec{Kind: "PipelineRun", APIVersion: "tekton.dev/v1"}
+	taskRuns := TargetSpec{Kind: "TaskRun", APIVersion: "tekton.dev/v1"}
+
+	tests := []struct {
+		name string
+		spec TTLReaperSpec
+		// wantErr lists the fields the errors are about, none if valid
+		wantErr []string
+	}{{
+		name: "target",
+		spec: TTLReaperSpec{
+			TargetKind:       "PipelineRun",
+			TargetAPIVersion: "tekton.dev/v1",
+			TargetSettings: TargetSettings{
+				TargetNamespace: "ci",
+				LabelSelector:   &metav1.LabelSelector{MatchLabels: map[string]string{"app": "build"}},
+				DefaultTTL:      hour,
+				MaxTTL:          day,
+			},
+		},
+	}, {
+		name: "targets",
+		spec: TTLReaperSpec{Targets: []TargetSpec{pipelineRuns, taskRuns}},
+	}, {
+		name:    "no target",
+		spec:    TTLReaperSpec{},
+		wantErr: []string{"targetKind", "targets"},
+	}, {
+		name: "single target and targets",
+		spec: TTLReaperSpec{
+			TargetKind:       "PipelineRun",
+			TargetAPIVersion: "tekton.dev/v1",
+			Targets:          []TargetSpec{pipelineRuns, taskRuns},
+		},
+		wantErr: []string{"targetKind", "targets"},
+	}, {
+		name: "single target with a named target",
+		spec: TTLReaperSpec{
+			TargetKind:       "PipelineRun",
+			TargetAPIVersion: "tekton.dev/v1",
+			TargetSettings:   TargetSettings{DefaultTTL: hour},
+			Targets: []TargetSpec{{
+				Name:           "runs",
+				Kind:           "PipelineRun",
+				APIVersion:     "tekton.dev/v1",
+				TargetSettings: TargetSettings{DefaultTTL: hour},
+			}},
+		},
+	}, {
+		name: "single target changed only in targets",
+		spec: TTLReaperSpec{
+			TargetKind:       "PipelineRun",
+			TargetAPIVersion: "tekton.dev/v1",
+			TargetSettings:   TargetSettings{DefaultTTL: hour},
+			Targets: []TargetSpec{{
+				Kind:           "PipelineRun",
+				APIVersion:     "tekton.dev/v1",
+				TargetSettings: TargetSettings{DefaultTTL: day},
+			}},
+		},
+		wantErr: []string{"targetKind", "targets[0]"},
+	}, {
+		name: "settings outside of targets",
+		spec: TTLReaperSpec{
+			Targets:        []TargetSpec{pipelineRuns},
+			TargetSettings: TargetSettings{DefaultTTL: hour},
+		},
+		wantErr: []string{"targets"},
+	}, {
+		name:    "duplicate target names",
+		spec:    TTLReaperSpec{Targets: []TargetSpec{pipelineRuns, pipelineRuns}},
+		wantErr: []string{"targets[1].name"},
+	}, {
+		name:    "target apiVersion without a version",
+		spec:    TTLReaperSpec{Targets: []TargetSpec{{Kind: "Job", APIVersion: "batch/"}}},
+		wantErr: []string{"targets[0].apiVersion"},
+	}, {
+		name: "target in a system namespace",
+		spec: TTLReaperSpec{Targets: []TargetSpec{{
+			Kind:           "Job",
+			APIVersion:     "batch/v1",
+			TargetSettings: TargetSettings{TargetNamespace: "kube-system"},
+		}}},
+		wantErr: []string{"targets[0].targetNamespace"},
+	}, {
+		name: "kind with a group",
+		spec: TTLReaperSpec{
+			TargetKind:       "PipelineRun.tekton.dev",
+			TargetAPIVersion: "tekton.dev/v1",
+		},
+		wantErr: []string{"targetKind"},
+	}, {
+		name:    "apiVersion without a version",
+		spec:    TTLReaperSpec{TargetKind: "Job", TargetAPIVersion: "batch/"},
+		wantErr: []string{"targetAPIVersion"},
+	}, {
+		name: "invalid namespace",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings:   TargetSettings{TargetNamespace: "CI"},
+		},
+		wantErr: []string{"targetNamespace"},
+	}, {
+		name: "invalid label selector",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				LabelSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "app",
+					Operator: metav1.LabelSelectorOpIn,
+				}}},
+			},
+		},
+		wantErr: []string{"labelSelector"},
+	}, {
+		name: "invalid TTL field path",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				TTLFieldPath: "{.spec.ttl",
+			},
+		},
+		wantErr: []string{"ttlFieldPath"},
+	}, {
+		name: "defaultTTL above maxTTL",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				DefaultTTL: day,
+				MaxTTL:     hour,
+			},
+		},
+		wantErr: []string{"defaultTTL", "maxTTL"},
+	}, {
+		name: "negative defaultTTL",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				DefaultTTL: &metav1.Duration{Duration: -time.Hour},
+			},
+		},
+		wantErr: []string{"defaultTTL"},
+	}, {
+		name: "finishedWhen",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings:   TargetSettings{FinishedWhen: "self.status.conditions.exists(c, c.type == 'Complete' && c.status == 'True')"},
+		},
+	}, {
+		name: "finishedWhen not returning a bool",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings:   TargetSettings{FinishedWhen: "'done'"},
+		},
+		wantErr: []string{"finishedWhen"},
+	}, {
+		name: "ttlAfterFailure above maxTTL",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				TTLAfterSuccess: hour,
+				TTLAfterFailure: day,
+				MaxTTL:          hour,
+			},
+		},
+		wantErr: []string{"ttlAfterFailure", "maxTTL"},
+	}, {
+		name: "outcome rule not returning a bool",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				Outcomes: &OutcomeRules{CancelledWhen: "'Cancelled'"},
+			},
+		},
+		wantErr: []string{"outcomes.cancelledWhen"},
+	}, {
+		name: "retention grouped both ways without a limit",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				Retention: &RetentionSpec{
+					GroupBy: RetentionGroupBy{Label: "app", OwnerKind: "CronJob"},
+				},
+			},
+		},
+		wantErr: []string{"retention.groupBy", "retention.successfulHistoryLimit"},
+	}, {
+		name: "non-positive maxAge",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				MaxAge: &MaxAgeSpec{},
+			},
+		},
+		wantErr: []string{"maxAge.age"},
+	}, {
+		name: "system namespace",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings:   TargetSettings{TargetNamespace: "kube-system"},
+		},
+		wantErr: []string{"targetNamespace"},
+	}, {
+		name: "included system namespace",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				TargetNamespace:          "kube-system",
+				IncludedSystemNamespaces: []string{"kube-system"},
+			},
+		},
+	}, {
+		name: "namespace and namespace selector",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				TargetNamespace:    "ci",
+				NamespaceSelector:  &metav1.LabelSelector{MatchLabels: map[string]string{"team": "ci"}},
+				ExcludedNamespaces: []string{"ci-prod"},
+			},
+		},
+		wantErr: []string{"namespaceSelector", "excludedNamespaces"},
+	}, {
+		name: "including a namespace that isn't a system one",
+		spec: TTLReaperSpec{
+			TargetKind:       "Job",
+			TargetAPIVersion: "batch/v1",
+			TargetSettings: TargetSettings{
+				IncludedSystemNamespaces: []string{"ci"},
+			},
+		},
+		wantErr: []string{"includedSystemNamespaces[0]"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			spec := test.spec.DeepCopy()
+			spec.SetDefaults(ctx)
+			errs := spec.Validate(ctx)
+			if len(test.wantErr) == 0 {
+				if errs != nil {
+					t.Errorf("Validate() = %v, want no error", errs)
+				}
+				return
+			}
+			if errs == nil {
+				t.Fatalf("Validate() = nil, want errors about %v", test.wantErr)
+			}
+			for _, field := range test.wantErr {
+				if !strings.Contains(errs.Error(), field) {
+					t.Errorf("Validate() = %v, want an error about %s", errs, field)
+				}
+			}
+		})
+	}
+}
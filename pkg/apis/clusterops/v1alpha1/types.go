@@ -2,11 +2,11 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
 )
 
 // +genclient
 // +genclient:nonNamespaced
-// +genclient:noStatus
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 type TTLReaper struct {
@@ -19,28 +19,453 @@ type TTLReaper struct {
 
 // TTLReaperSpec defines the desired state of TTLReaper
 type TTLReaperSpec struct {
-	// TargetKind specifies the kind of custom resource to monitor for TTL expiration
-	TargetKind string `json:"targetKind"`
+	// Targets lists the kinds to reap, each with its own selectors and TTL
+	// settings
+	Targets []TargetSpec `json:"targets,omitempty"`
+
+	// TargetKind specifies the kind of custom resource to monitor for TTL expiration.
+	// Together with TargetAPIVersion and the inline TargetSettings it describes
+	// a single target, the form TTLReapers had before Targets. Defaulting turns
+	// it into the only entry of Targets; it can't be combined with other entries.
+	TargetKind string `json:"targetKind,omitempty"`
 
 	// TargetAPIVersion specifies the API version of the target custom resource
-	TargetAPIVersion string `json:"targetAPIVersion"`
+	TargetAPIVersion string `json:"targetAPIVersion,omitempty"`
+
+	// TargetSettings of the single target described by TargetKind
+	TargetSettings `json:",inline"`
+
+	// DryRun makes the reaper evaluate and schedule deletions as usual but
+	// only report, through logs, Events and status.dryRunDeletions, what it
+	// would have deleted. Turning it off starts reaping right away.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// HasSingleTarget reports whether the spec uses the single-target fields
+func (s *TTLReaperSpec) HasSingleTarget() bool {
+	return s.TargetKind != "" || s.TargetAPIVersion != ""
+}
+
+// SingleTarget returns the target described by the single-target fields
+func (s *TTLReaperSpec) SingleTarget() TargetSpec {
+	return TargetSpec{
+		Kind:           s.TargetKind,
+		APIVersion:     s.TargetAPIVersion,
+		TargetSettings: *s.TargetSettings.DeepCopy(),
+	}
+}
+
+// TargetSpec is one kind a TTLReaper reaps
+type TargetSpec struct {
+	// Name identifies the target in status. Defaults to the lowercased kind;
+	// it must be unique within the TTLReaper.
+	Name string `json:"name,omitempty"`
+
+	// Kind of the objects to reap, e.g. PipelineRun
+	Kind string `json:"kind"`
+
+	// APIVersion of the objects to reap, e.g. tekton.dev/v1
+	APIVersion string `json:"apiVersion"`
 
+	// TargetSettings select the objects and decide when they expire
+	TargetSettings `json:",inline"`
+}
+
+// TargetSettings select the objects of a target and decide when they expire
+type TargetSettings struct {
 	// TargetNamespace specifies the namespace to monitor. If empty, monitors all namespaces
+	// matched by NamespaceSelector and not excluded.
+	// It is ignored when the target kind is cluster-scoped.
 	TargetNamespace string `json:"targetNamespace,omitempty"`
 
+	// NamespaceSelector restricts the monitored namespaces to those whose
+	// labels match (optional). It can't be combined with TargetNamespace.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// ExcludedNamespaces are never monitored (optional). They can't be
+	// combined with TargetNamespace.
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+
+	// IncludedSystemNamespaces lets the reaper into the given SystemNamespaces,
+	// which it otherwise never touches (optional)
+	IncludedSystemNamespaces []string `json:"includedSystemNamespaces,omitempty"`
+
 	// LabelSelector to filter which resources to monitor (optional)
 	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+
+	// TTLFieldPath is the JSONPath of the field holding each object's TTL, e.g.
+	// ".spec.ttlStrategy.secondsAfterCompletion". The field may hold an integer
+	// number of seconds or a Go duration string such as "36h".
+	// Defaults to ".spec.ttlSecondsAfterFinished".
+	TTLFieldPath string `json:"ttlFieldPath,omitempty"`
+
+	// DefaultTTL applies to objects that don't carry a TTL of their own and
+	// that no outcome-specific TTL applies to. Without it, such objects are
+	// never reaped.
+	DefaultTTL *metav1.Duration `json:"defaultTTL,omitempty"`
+
+	// TTLAfterSuccess applies to succeeded objects that don't carry a TTL of
+	// their own
+	TTLAfterSuccess *metav1.Duration `json:"ttlAfterSuccess,omitempty"`
+
+	// TTLAfterFailure applies to failed and cancelled objects that don't
+	// carry a TTL of their own
+	TTLAfterFailure *metav1.Duration `json:"ttlAfterFailure,omitempty"`
+
+	// TTLAfterCompletion applies to finished objects of any outcome that don't
+	// carry a TTL of their own, unless TTLAfterSuccess or TTLAfterFailure does.
+	// It takes precedence over DefaultTTL.
+	TTLAfterCompletion *metav1.Duration `json:"ttlAfterCompletion,omitempty"`
+
+	// MaxTTL caps the TTLs that objects set themselves (optional)
+	MaxTTL *metav1.Duration `json:"maxTTL,omitempty"`
+
+	// FinishedWhen is a CEL expression that decides whether an object has
+	// finished, e.g. `self.status.conditions.exists(c, c.type == "Succeeded" && c.status != "Unknown")`.
+	// The object is bound to `self` and the expression must evaluate to a bool.
+	// When empty, the built-in phase/condition/completionTime heuristics are used.
+	FinishedWhen string `json:"finishedWhen,omitempty"`
+
+	// Outcomes override how finished objects are classified as succeeded,
+	// failed or cancelled (optional)
+	Outcomes *OutcomeRules `json:"outcomes,omitempty"`
+
+	// FinishTimeSources are tried in order to find when a finished object
+	// finished; the first one that yields a time wins. TTLs count from that
+	// time. Defaults to DefaultFinishTimeSources.
+	FinishTimeSources []FinishTimeSource `json:"finishTimeSources,omitempty"`
+
+	// MissingFinishTime decides what happens to finished objects for which no
+	// finish time source yields a time. Defaults to FirstObserved.
+	MissingFinishTime MissingFinishTimePolicy `json:"missingFinishTime,omitempty"`
+
+	// Retention keeps only the newest finished objects of each group, on top
+	// of the TTLs (optional)
+	Retention *RetentionSpec `json:"retention,omitempty"`
+
+	// MaxAge deletes matching objects once they are older than its age,
+	// whether they finished or not. Objects that never finish are only ever
+	// reaped this way. Off unless set.
+	MaxAge *MaxAgeSpec `json:"maxAge,omitempty"`
 }
 
+// MaxAgeSpec is the opt-in for deleting objects by age alone
+type MaxAgeSpec struct {
+	// Age after creationTimestamp at which objects are deleted
+	Age metav1.Duration `json:"age"`
+}
+
+// RetentionSpec sets history limits per group of objects, like a CronJob's
+// successfulJobsHistoryLimit and failedJobsHistoryLimit. Objects are ordered
+// by finish time, and the ones beyond the limits are deleted regardless of
+// their TTL.
+type RetentionSpec struct {
+	// GroupBy tells which objects are counted together
+	GroupBy RetentionGroupBy `json:"groupBy"`
+
+	// SuccessfulHistoryLimit is how many succeeded objects to keep per group.
+	// Unlimited when unset.
+	SuccessfulHistoryLimit *int32 `json:"successfulHistoryLimit,omitempty"`
+
+	// FailedHistoryLimit is how many failed and cancelled objects to keep per
+	// group. Unlimited when unset.
+	FailedHistoryLimit *int32 `json:"failedHistoryLimit,omitempty"`
+}
+
+// RetentionGroupBy names what groups objects. Exactly one of Label and
+// OwnerKind must be set. Objects of the same namespace are grouped together
+// when they have the same label value or owner; objects without one are not
+// subject to retention.
+type RetentionGroupBy struct {
+	// Label is the key of the label whose value groups objects, e.g.
+	// "tekton.dev/pipeline"
+	Label string `json:"label,omitempty"`
+
+	// OwnerKind groups objects by their ownerReference of this kind, e.g. CronJob
+	OwnerKind string `json:"ownerKind,omitempty"`
+}
+
+// OutcomeRules are CEL expressions over `self` that classify objects. They
+// are tried in the order cancelledWhen, failedWhen, succeededWhen; the first
+// one that evaluates to true decides the outcome and marks the object as
+// finished. Objects none of them matches are classified by the built-in
+// heuristics.
+type OutcomeRules struct {
+	// SucceededWhen matches succeeded objects
+	SucceededWhen string `json:"succeededWhen,omitempty"`
+
+	// FailedWhen matches failed objects
+	FailedWhen string `json:"failedWhen,omitempty"`
+
+	// CancelledWhen matches cancelled objects
+	CancelledWhen string `json:"cancelledWhen,omitempty"`
+}
+
+// Outcome is how a finished object ended
+type Outcome string
+
+const (
+	// OutcomeSucceeded is the outcome of objects that completed successfully
+	OutcomeSucceeded Outcome = "Succeeded"
+
+	// OutcomeFailed is the outcome of objects that failed
+	OutcomeFailed Outcome = "Failed"
+
+	// OutcomeCancelled is the outcome of objects that were cancelled
+	OutcomeCancelled Outcome = "Cancelled"
+
+	// OutcomeUnknown is the outcome of finished objects that don't tell how
+	// they ended
+	OutcomeUnknown Outcome = "Unknown"
+)
+
+// FinishTimeSource is one place to read an object's finish time from.
+// Exactly one of FieldPath and Expression must be set.
+type FinishTimeSource struct {
+	// FieldPath is the JSONPath of a field holding an RFC 3339 timestamp,
+	// e.g. ".status.completionTime"
+	FieldPath string `json:"fieldPath,omitempty"`
+
+	// Expression is a CEL expression over `self` that evaluates to a
+	// timestamp or an RFC 3339 string, or to null or an empty optional when
+	// the object carries no finish time
+	Expression string `json:"expression,omitempty"`
+}
+
+// MissingFinishTimePolicy tells the reaper what to do with finished objects
+// whose finish time can't be found
+type MissingFinishTimePolicy string
+
+const (
+	// MissingFinishTimeSkip leaves such objects alone
+	MissingFinishTimeSkip MissingFinishTimePolicy = "Skip"
+
+	// MissingFinishTimeFirstObserved counts their TTL from when the controller
+	// first saw them finished. That time is kept in memory, and recorded on
+	// the objects in the FinishObservedAtAnnotation when the controller is
+	// configured to, so restarts and changes of leader keep it.
+	MissingFinishTimeFirstObserved MissingFinishTimePolicy = "FirstObserved"
+
+	// MissingFinishTimeCreationTime counts their TTL from their creationTimestamp
+	MissingFinishTimeCreationTime MissingFinishTimePolicy = "CreationTime"
+)
+
+// DefaultFinishTimeSources returns the finish time sources used unless
+// FinishTimeSources says otherwise: Job and Tekton's status.completionTime,
+// Argo's status.finishedAt, and the lastTransitionTime of a terminal
+// Succeeded, Completed, Complete or Failed condition.
+func DefaultFinishTimeSources() []FinishTimeSource {
+	return []FinishTimeSource{
+		{FieldPath: ".status.completionTime"},
+		{FieldPath: ".status.finishedAt"},
+		{Expression: "self.?status.?conditions.orValue([])" +
+			".filter(c, has(c.lastTransitionTime) && ((c.type == 'Succeeded' && c.status != 'Unknown') || (c.type in ['Completed', 'Complete', 'Failed'] && c.status == 'True')))" +
+			".map(c, c.lastTransitionTime)[?0]"},
+	}
+}
+
+// SystemNamespaces are left alone by every reaper unless it lists them in
+// IncludedSystemNamespaces
+var SystemNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// IsSystemNamespace reports whether namespace is one of SystemNamespaces
+func IsSystemNamespace(namespace string) bool {
+	for _, ns := range SystemNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// IncludesSystemNamespace reports whether the settings explicitly let the
+// reaper into the given system namespace
+func (s *TargetSettings) IncludesSystemNamespace(namespace string) bool {
+	for _, ns := range s.IncludedSystemNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultTTLFieldPath is where TTLs are read from unless TTLFieldPath says otherwise
+const DefaultTTLFieldPath = ".spec.ttlSecondsAfterFinished"
+
 // TTLReaperStatus defines the observed state of TTLReaper
 type TTLReaperStatus struct {
-	// LastProcessedTime tracks when the reaper last processed resources
+	// Status carries observedGeneration and the Ready, TargetResolved,
+	// PolicyValid and Degraded conditions
+	duckv1.Status `json:",inline"`
+
+	// LastProcessedTime tracks when the reaper last processed resources in a
+	// way that changed its status. Sweeps that change nothing leave it be.
 	LastProcessedTime *metav1.Time `json:"lastProcessedTime,omitempty"`
 
 	// TotalReaped tracks total number of resources cleaned up
 	TotalReaped int32 `json:"totalReaped,omitempty"`
+
+	// TotalPruned tracks the number of resources deleted because they
+	// exceeded the retention limits. They are not part of TotalReaped.
+	TotalPruned int32 `json:"totalPruned,omitempty"`
+
+	// TotalMaxAgeReaped tracks the number of resources deleted because they
+	// outlived maxAge. They are not part of TotalReaped.
+	TotalMaxAgeReaped int32 `json:"totalMaxAgeReaped,omitempty"`
+
+	// TotalFailedDeletions tracks the number of deletions of a resource that
+	// failed, each counted once however often it was retried
+	TotalFailedDeletions int32 `json:"totalFailedDeletions,omitempty"`
+
+	// Matched is the number of target objects matched by the last reconcile
+	Matched int32 `json:"matched"`
+
+	// Pending is the number of objects waiting for their TTL or maxAge to expire
+	Pending int32 `json:"pending"`
+
+	// NextDeletionTime is the earliest scheduled deletion among pending objects
+	NextDeletionTime *metav1.Time `json:"nextDeletionTime,omitempty"`
+
+	// ScheduledDeletions lists the soonest pending deletions across all
+	// targets, at most MaxScheduledDeletions of them
+	ScheduledDeletions []ScheduledDeletion `json:"scheduledDeletions,omitempty"`
+
+	// DryRunDeletions lists objects a dry-run reaper would have deleted by
+	// now, with when, at most MaxScheduledDeletions of them
+	DryRunDeletions []ScheduledDeletion `json:"dryRunDeletions,omitempty"`
+
+	// Targets reports what the last reconcile found for each target, in the
+	// order of spec.targets
+	Targets []TargetStatus `json:"targets,omitempty"`
+}
+
+// TargetStatus is the observed state of one target
+type TargetStatus struct {
+	// Name of the target in spec.targets
+	Name string `json:"name"`
+
+	// Resource is the resource the target kind resolved to, e.g.
+	// "pipelineruns.v1.tekton.dev"
+	Resource string `json:"resource,omitempty"`
+
+	// Scope reports whether the target kind is namespaced or cluster-scoped,
+	// as resolved through API discovery
+	Scope TargetScope `json:"scope,omitempty"`
+
+	// NamespaceIgnored is true when TargetNamespace, NamespaceSelector or
+	// ExcludedNamespaces is set but the target kind is cluster-scoped, so
+	// they had no effect
+	NamespaceIgnored bool `json:"namespaceIgnored,omitempty"`
+
+	// Namespaces is the number of namespaces the last reconcile processed
+	Namespaces int32 `json:"namespaces,omitempty"`
+
+	// Matched is the number of objects of the target matched by the last reconcile
+	Matched int32 `json:"matched"`
+
+	// Pending is the number of objects of the target waiting to expire
+	Pending int32 `json:"pending"`
+
+	// NextDeletionTime is the earliest scheduled deletion of the target
+	NextDeletionTime *metav1.Time `json:"nextDeletionTime,omitempty"`
+
+	// Reason and Message say why the target could not be fully processed,
+	// and are empty when it was
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
 }
 
+// MaxScheduledDeletions bounds the number of entries in status.scheduledDeletions
+const MaxScheduledDeletions = 10
+
+// ScheduledDeletion describes one pending deletion
+type ScheduledDeletion struct {
+	// Target is the name of the target the object belongs to
+	Target string `json:"target,omitempty"`
+
+	// Namespace of the object, empty for cluster-scoped kinds
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the object
+	Name string `json:"name"`
+
+	// ExpirationTime is when the object will be, or for dry runs would have
+	// been, deleted
+	ExpirationTime metav1.Time `json:"expirationTime"`
+
+	// Reason tells why the object is going to be deleted
+	Reason DeletionReason `json:"reason,omitempty"`
+
+	// TTLSource tells where the TTL used for the object came from. It is
+	// empty when the object is deleted for exceeding maxAge or its deadline.
+	TTLSource TTLSource `json:"ttlSource,omitempty"`
+
+	// Outcome is how the object ended, empty if it hasn't finished
+	Outcome Outcome `json:"outcome,omitempty"`
+}
+
+// DeletionReason tells why the reaper deletes an object
+type DeletionReason string
+
+const (
+	// DeletionReasonTTLExpired is the reason for deleting finished objects
+	// whose TTL expired
+	DeletionReasonTTLExpired DeletionReason = "TTLExpired"
+
+	// DeletionReasonDeadlineReached is the reason for deleting objects whose
+	// ttl.clusterops.io/expires-at time passed
+	DeletionReasonDeadlineReached DeletionReason = "DeadlineReached"
+
+	// DeletionReasonMaxAgeExceeded is the reason for deleting objects older
+	// than maxAge, finished or not
+	DeletionReasonMaxAgeExceeded DeletionReason = "MaxAgeExceeded"
+
+	// DeletionReasonRetentionLimitExceeded is the reason for deleting finished
+	// objects beyond their group's history limits
+	DeletionReasonRetentionLimitExceeded DeletionReason = "RetentionLimitExceeded"
+)
+
+// TTLSource tells where the TTL of a scheduled deletion came from
+type TTLSource string
+
+const (
+	// TTLSourceAnnotation is the TTL set by the object's ttl.clusterops.io/ttl annotation
+	TTLSourceAnnotation TTLSource = "Annotation"
+
+	// TTLSourceObject is the TTL the object carries in its TTL field
+	TTLSourceObject TTLSource = "Object"
+
+	// TTLSourceMaxTTL is the reaper's maxTTL, used because the object's own TTL
+	// or its annotation exceeded it
+	TTLSourceMaxTTL TTLSource = "MaxTTL"
+
+	// TTLSourceAfterSuccess is the reaper's ttlAfterSuccess, used because the
+	// object succeeded and carries no TTL
+	TTLSourceAfterSuccess TTLSource = "AfterSuccess"
+
+	// TTLSourceAfterFailure is the reaper's ttlAfterFailure, used because the
+	// object failed or was cancelled and carries no TTL
+	TTLSourceAfterFailure TTLSource = "AfterFailure"
+
+	// TTLSourceAfterCompletion is the reaper's ttlAfterCompletion, used because
+	// the object carries no TTL
+	TTLSourceAfterCompletion TTLSource = "AfterCompletion"
+
+	// TTLSourceDefault is the reaper's defaultTTL, used because the object carries no TTL
+	TTLSourceDefault TTLSource = "Default"
+)
+
+// TargetScope describes whether a target kind lives in namespaces or at cluster level
+type TargetScope string
+
+const (
+	// TargetScopeNamespaced is the scope of kinds whose objects live in a namespace
+	TargetScopeNamespaced TargetScope = "Namespaced"
+
+	// TargetScopeCluster is the scope of cluster-scoped kinds
+	TargetScopeCluster TargetScope = "Cluster"
+)
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // TTLReaperList contains a list of TTLReaper
@@ -25,6 +25,115 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FinishTimeSource) DeepCopyInto(out *FinishTimeSource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FinishTimeSource.
+func (in *FinishTimeSource) DeepCopy() *FinishTimeSource {
+	if in == nil {
+		return nil
+	}
+	out := new(FinishTimeSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaxAgeSpec) DeepCopyInto(out *MaxAgeSpec) {
+	*out = *in
+	out.Age = in.Age
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaxAgeSpec.
+func (in *MaxAgeSpec) DeepCopy() *MaxAgeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MaxAgeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutcomeRules) DeepCopyInto(out *OutcomeRules) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutcomeRules.
+func (in *OutcomeRules) DeepCopy() *OutcomeRules {
+	if in == nil {
+		return nil
+	}
+	out := new(OutcomeRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetentionGroupBy) DeepCopyInto(out *RetentionGroupBy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetentionGroupBy.
+func (in *RetentionGroupBy) DeepCopy() *RetentionGroupBy {
+	if in == nil {
+		return nil
+	}
+	out := new(RetentionGroupBy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetentionSpec) DeepCopyInto(out *RetentionSpec) {
+	*out = *in
+	out.GroupBy = in.GroupBy
+	if in.SuccessfulHistoryLimit != nil {
+		in, out := &in.SuccessfulHistoryLimit, &out.SuccessfulHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailedHistoryLimit != nil {
+		in, out := &in.FailedHistoryLimit, &out.FailedHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetentionSpec.
+func (in *RetentionSpec) DeepCopy() *RetentionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RetentionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledDeletion) DeepCopyInto(out *ScheduledDeletion) {
+	*out = *in
+	in.ExpirationTime.DeepCopyInto(&out.ExpirationTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledDeletion.
+func (in *ScheduledDeletion) DeepCopy() *ScheduledDeletion {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledDeletion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TTLReaper) DeepCopyInto(out *TTLReaper) {
 	*out = *in
@@ -89,11 +198,14 @@ func (in *TTLReaperList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TTLReaperSpec) DeepCopyInto(out *TTLReaperSpec) {
 	*out = *in
-	if in.LabelSelector != nil {
-		in, out := &in.LabelSelector, &out.LabelSelector
-		*out = new(v1.LabelSelector)
-		(*in).DeepCopyInto(*out)
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
+	in.TargetSettings.DeepCopyInto(&out.TargetSettings)
 	return
 }
 
@@ -110,10 +222,36 @@ func (in *TTLReaperSpec) DeepCopy() *TTLReaperSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TTLReaperStatus) DeepCopyInto(out *TTLReaperStatus) {
 	*out = *in
+	in.Status.DeepCopyInto(&out.Status)
 	if in.LastProcessedTime != nil {
 		in, out := &in.LastProcessedTime, &out.LastProcessedTime
 		*out = (*in).DeepCopy()
 	}
+	if in.NextDeletionTime != nil {
+		in, out := &in.NextDeletionTime, &out.NextDeletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ScheduledDeletions != nil {
+		in, out := &in.ScheduledDeletions, &out.ScheduledDeletions
+		*out = make([]ScheduledDeletion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DryRunDeletions != nil {
+		in, out := &in.DryRunDeletions, &out.DryRunDeletions
+		*out = make([]ScheduledDeletion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -126,3 +264,121 @@ func (in *TTLReaperStatus) DeepCopy() *TTLReaperStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetSettings) DeepCopyInto(out *TargetSettings) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExcludedNamespaces != nil {
+		in, out := &in.ExcludedNamespaces, &out.ExcludedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IncludedSystemNamespaces != nil {
+		in, out := &in.IncludedSystemNamespaces, &out.IncludedSystemNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DefaultTTL != nil {
+		in, out := &in.DefaultTTL, &out.DefaultTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TTLAfterSuccess != nil {
+		in, out := &in.TTLAfterSuccess, &out.TTLAfterSuccess
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TTLAfterFailure != nil {
+		in, out := &in.TTLAfterFailure, &out.TTLAfterFailure
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TTLAfterCompletion != nil {
+		in, out := &in.TTLAfterCompletion, &out.TTLAfterCompletion
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxTTL != nil {
+		in, out := &in.MaxTTL, &out.MaxTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Outcomes != nil {
+		in, out := &in.Outcomes, &out.Outcomes
+		*out = new(OutcomeRules)
+		**out = **in
+	}
+	if in.FinishTimeSources != nil {
+		in, out := &in.FinishTimeSources, &out.FinishTimeSources
+		*out = make([]FinishTimeSource, len(*in))
+		copy(*out, *in)
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(RetentionSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(MaxAgeSpec)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetSettings.
+func (in *TargetSettings) DeepCopy() *TargetSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetSpec) DeepCopyInto(out *TargetSpec) {
+	*out = *in
+	in.TargetSettings.DeepCopyInto(&out.TargetSettings)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetSpec.
+func (in *TargetSpec) DeepCopy() *TargetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetStatus) DeepCopyInto(out *TargetStatus) {
+	*out = *in
+	if in.NextDeletionTime != nil {
+		in, out := &in.NextDeletionTime, &out.NextDeletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetStatus.
+func (in *TargetStatus) DeepCopy() *TargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
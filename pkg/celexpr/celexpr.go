@@ -0,0 +1,142 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package celexpr compiles and evaluates CEL expressions against
+// unstructured Kubernetes objects, which are bound to the variable `self`.
+package celexpr
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"k8s.io/apiserver/pkg/cel/library"
+)
+
+// costLimit bounds the work a single evaluation may do, so that an
+// expression can't stall a reconcile.
+const costLimit = 1000000
+
+// newEnv returns the environment expressions are compiled in. Along with
+// the generic cel-go extensions, it has the Kubernetes libraries (lists,
+// regex, URLs and quantity) so that expressions behave like the CEL of CRD
+// validation rules.
+var newEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("self", cel.DynType),
+		cel.OptionalTypes(),
+		ext.Strings(),
+		ext.Lists(),
+		ext.Sets(),
+		ext.Math(),
+		ext.Encoders(),
+		library.Lists(),
+		library.Regex(),
+		library.URLs(),
+		library.Quantity(),
+	)
+})
+
+// Program is a compiled CEL expression. It is safe for concurrent use.
+type Program struct {
+	expression string
+	program    cel.Program
+}
+
+// Compile parses and type-checks expression. When outputType is not nil the
+// expression must evaluate to that type (or to dyn, which is checked when it
+// is evaluated).
+func Compile(expression string, outputType *cel.Type) (*Program, error) {
+	env, err := newEnv()
+	if err != nil {
+		return nil, fmt.Errorf("creating CEL environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if outputType != nil && !ast.OutputType().IsExactType(cel.DynType) && !ast.OutputType().IsExactType(outputType) {
+		return nil, fmt.Errorf("expression must evaluate to %s, not %s", outputType, ast.OutputType())
+	}
+
+	program, err := env.Program(ast, cel.CostLimit(costLimit))
+	if err != nil {
+		return nil, err
+	}
+	return &Program{expression: expression, program: program}, nil
+}
+
+// String returns the source of the expression.
+func (p *Program) String() string {
+	return p.expression
+}
+
+// Eval evaluates the expression with obj bound to `self`.
+func (p *Program) Eval(obj map[string]interface{}) (ref.Val, error) {
+	out, _, err := p.program.Eval(map[string]interface{}{"self": obj})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EvalBool evaluates an expression that must produce a bool.
+func (p *Program) EvalBool(obj map[string]interface{}) (bool, error) {
+	out, err := p.Eval(obj)
+	if err != nil {
+		return false, err
+	}
+	b, ok := out.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("expression evaluated to %s, not bool", out.Type())
+	}
+	return bool(b), nil
+}
+
+// EvalTime evaluates an expression that produces a point in time: a CEL
+// timestamp or an RFC 3339 string. found is false when the expression
+// evaluates to null or to an empty optional.
+func (p *Program) EvalTime(obj map[string]interface{}) (t time.Time, found bool, err error) {
+	out, err := p.Eval(obj)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if opt, ok := out.(*types.Optional); ok {
+		if !opt.HasValue() {
+			return time.Time{}, false, nil
+		}
+		out = opt.GetValue()
+	}
+	switch v := out.(type) {
+	case types.Null:
+		return time.Time{}, false, nil
+	case types.Timestamp:
+		return v.Time, true, nil
+	case types.String:
+		t, err := time.Parse(time.RFC3339Nano, string(v))
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		return t, true, nil
+	default:
+		return time.Time{}, false, fmt.Errorf("expression evaluated to %s, not a timestamp", out.Type())
+	}
+}
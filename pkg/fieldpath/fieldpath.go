@@ -0,0 +1,83 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fieldpath looks up values in unstructured objects by JSONPath,
+// e.g. ".spec.ttlStrategy.secondsAfterCompletion".
+package fieldpath
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Path is a parsed JSONPath expression. It is safe for concurrent use.
+type Path struct {
+	raw string
+
+	// mu serializes lookups, as jsonpath.JSONPath keeps state while
+	// evaluating
+	mu sync.Mutex
+	jp *jsonpath.JSONPath
+}
+
+// Parse parses a JSONPath. Both the bare form (".spec.foo" or "spec.foo")
+// and the kubectl template form ("{.spec.foo}") are accepted.
+func Parse(path string) (*Path, error) {
+	jp := jsonpath.New(path).AllowMissingKeys(true)
+	if err := jp.Parse(template(path)); err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %w", path, err)
+	}
+	return &Path{raw: path, jp: jp}, nil
+}
+
+// template converts a bare path into a jsonpath template.
+func template(path string) string {
+	path = strings.TrimSpace(path)
+	if strings.HasPrefix(path, "{") {
+		return path
+	}
+	if !strings.HasPrefix(path, ".") {
+		path = "." + path
+	}
+	return "{" + path + "}"
+}
+
+// String returns the path as it was given to Parse.
+func (p *Path) String() string {
+	return p.raw
+}
+
+// Lookup returns the first value the path selects in obj, and false if it
+// selects nothing.
+func (p *Path) Lookup(obj map[string]interface{}) (interface{}, bool, error) {
+	p.mu.Lock()
+	results, err := p.jp.FindResults(obj)
+	p.mu.Unlock()
+	if err != nil {
+		return nil, false, fmt.Errorf("evaluating %q: %w", p.raw, err)
+	}
+	for _, result := range results {
+		for _, value := range result {
+			if value.IsValid() && value.CanInterface() && value.Interface() != nil {
+				return value.Interface(), true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
@@ -38,6 +38,8 @@ type TTLReapersGetter interface {
 type TTLReaperInterface interface {
 	Create(ctx context.Context, tTLReaper *clusteropsv1alpha1.TTLReaper, opts v1.CreateOptions) (*clusteropsv1alpha1.TTLReaper, error)
 	Update(ctx context.Context, tTLReaper *clusteropsv1alpha1.TTLReaper, opts v1.UpdateOptions) (*clusteropsv1alpha1.TTLReaper, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, tTLReaper *clusteropsv1alpha1.TTLReaper, opts v1.UpdateOptions) (*clusteropsv1alpha1.TTLReaper, error)
 	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
 	Get(ctx context.Context, name string, opts v1.GetOptions) (*clusteropsv1alpha1.TTLReaper, error)
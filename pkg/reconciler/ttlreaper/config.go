@@ -0,0 +1,67 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/configmap"
+)
+
+const (
+	// configName is the name of the ConfigMap tuning the controller, in the
+	// system namespace
+	configName = "config-ttlreaper"
+
+	// deleteWorkersKey is how many expired objects may be deleted at once
+	deleteWorkersKey = "delete-workers"
+
+	// targetEventsKey enables WillBeReaped Events on target objects when
+	// their deletion is scheduled
+	targetEventsKey = "target-events"
+
+	// annotateFinishObservedKey enables recording on target objects when
+	// they were first observed finished, which needs the patch verb on them
+	annotateFinishObservedKey = "annotate-finish-observed"
+
+	defaultDeleteWorkers = 10
+)
+
+// reaperConfig is the controller's tuning, from the config-ttlreaper ConfigMap.
+type reaperConfig struct {
+	deleteWorkers          int
+	targetEvents           bool
+	annotateFinishObserved bool
+}
+
+// newReaperConfigFromConfigMap parses the config-ttlreaper ConfigMap,
+// defaulting what it leaves out.
+func newReaperConfigFromConfigMap(cm *corev1.ConfigMap) (*reaperConfig, error) {
+	c := &reaperConfig{deleteWorkers: defaultDeleteWorkers}
+	if err := configmap.Parse(cm.Data,
+		configmap.AsInt(deleteWorkersKey, &c.deleteWorkers),
+		configmap.AsBool(targetEventsKey, &c.targetEvents),
+		configmap.AsBool(annotateFinishObservedKey, &c.annotateFinishObserved),
+	); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configName, err)
+	}
+	if c.deleteWorkers < 1 {
+		return nil, fmt.Errorf("%s: %s must be at least 1, got %d", configName, deleteWorkersKey, c.deleteWorkers)
+	}
+	return c, nil
+}
@@ -18,31 +18,63 @@ package ttlreaper
 
 import (
 	"context"
-	"fmt"
 	"strings"
 	"time"
 
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/reconciler"
 
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+	ttlreaperclient "github.com/infernus01/knative-demo/pkg/client/injection/client"
 	ttlreaperinformer "github.com/infernus01/knative-demo/pkg/client/injection/informers/clusterops/v1alpha1/ttlreaper"
+	ttlreaperscheme "github.com/infernus01/knative-demo/pkg/generated/clientset/versioned/scheme"
 
+	crdinformer "knative.dev/pkg/client/injection/apiextensions/informers/apiextensions/v1/customresourcedefinition"
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	namespaceinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/namespace"
 	dynamicclient "knative.dev/pkg/injection/clients/dynamicclient"
 )
 
+func init() {
+	// Register the TTLReaper types so that Events can refer to TTLReapers
+	utilruntime.Must(ttlreaperscheme.AddToScheme(scheme.Scheme))
+}
+
 const (
 	// controllerAgentName is the string used by this controller to identify
 	// itself when creating events.
 	controllerAgentName = "ttlreaper-controller"
+
+	// fullSweepInterval is how often every TTLReaper evaluates all of its
+	// target objects, on top of evaluating each object as it changes
+	fullSweepInterval = 10 * time.Minute
+
+	// statusFlushDelay is how long deletions wait to be written to their
+	// TTLReaper's status, so that a burst of them makes a single update
+	statusFlushDelay = 10 * time.Second
+
+	// objectWorkers is the number of target objects evaluated concurrently
+	objectWorkers = 4
 )
 
 // NewController creates a Reconciler and returns the result of NewImpl.
@@ -52,144 +84,270 @@ func NewController(
 ) *controller.Impl {
 	logger := logging.FromContext(ctx)
 
+	// Every Event goes through the recorder shared in ctx
+	ctx = withEventRecorder(ctx)
+
 	ttlreaperInformer := ttlreaperinformer.Get(ctx)
+	crdInformer := crdinformer.Get(ctx)
+	namespaceInformer := namespaceinformer.Get(ctx)
+	kubeClient := kubeclient.Get(ctx)
 
 	c := &Reconciler{
-		kubeclientset:   kubeclient.Get(ctx),
-		dynamicClient:   dynamicclient.Get(ctx),
-		ttlreaperLister: ttlreaperInformer.Lister(),
-		timers:          make(map[string]*time.Timer),
+		kubeclientset:      kubeClient,
+		clientset:          ttlreaperclient.Get(ctx),
+		dynamicClient:      dynamicclient.Get(ctx),
+		ttlreaperLister:    ttlreaperInformer.Lister(),
+		namespaceLister:    namespaceInformer.Lister(),
+		resolver:           newTargetResolver(kubeClient.Discovery()),
+		deletions:          make(map[string]deletionCounts),
+		completions:        newObservationTracker(),
+		dryRunReports:      newObservationTracker(),
+		evaluationFailures: newObservationTracker(),
+		recorder:           controller.GetEventRecorder(ctx),
+		objectQueue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[objectRef](),
+			workqueue.TypedRateLimitingQueueConfig[objectRef]{Name: controllerAgentName + "-objects"}),
+		deleteQueue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[deletionKey](),
+			workqueue.TypedRateLimitingQueueConfig[deletionKey]{Name: controllerAgentName + "-deletions"}),
+		statusQueue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: controllerAgentName + "-status"}),
+		policies: newPolicyCache(),
+	}
+	c.schedule = newDeletionSchedule(clock.RealClock{}, c.enqueueDeletion)
+	c.PromoteFunc = func(bkt reconciler.Bucket, enq func(reconciler.Bucket, types.NamespacedName)) error {
+		return c.promote(ctx, bkt, enq)
+	}
+	c.DemoteFunc = func(bkt reconciler.Bucket) {
+		c.demote(ctx, bkt)
 	}
+	c.watches = newTargetWatches(func(gvr schema.GroupVersionResource, namespace string, selector labels.Selector) cache.SharedIndexInformer {
+		return c.newTargetInformer(ctx, gvr, namespace, selector)
+	})
 
 	impl := controller.NewContext(ctx, c, controller.ControllerOptions{
 		WorkQueueName: controllerAgentName,
 		Logger:        logger,
 	})
 
+	c.enqueueKey = impl.EnqueueKey
+
 	logger.Info("Setting up event handlers")
 
-	// Set up an event handler for when TTLReaper resources change
-	ttlreaperInformer.Informer().AddEventHandler(controller.HandleAll(impl.Enqueue))
+	// Set up an event handler for when TTLReaper resources change. Updates that
+	// leave the generation alone only touched status (most likely our own
+	// write), so they are skipped; resyncs still come through. Reconciling a
+	// TTLReaper starts (and stops) watching its targets, so new and edited
+	// reapers watch them right away.
+	ttlreaperInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: impl.Enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldReaper, newReaper := oldObj.(*v1alpha1.TTLReaper), newObj.(*v1alpha1.TTLReaper)
+			if oldReaper.Generation != newReaper.Generation || oldReaper.ResourceVersion == newReaper.ResourceVersion {
+				impl.Enqueue(newObj)
+			}
+		},
+		DeleteFunc: impl.Enqueue,
+	})
+
+	// When CRDs change, previously unknown target kinds may have become
+	// resolvable (or known ones may have gone away), so drop the cached
+	// discovery data and reconcile every TTLReaper again.
+	crdInformer.Informer().AddEventHandler(controller.HandleAll(func(interface{}) {
+		c.resolver.reset()
+		impl.GlobalResync(ttlreaperInformer.Informer())
+	}))
+
+	// New namespaces, and namespaces whose labels changed, may have to be
+	// picked up by (or dropped from) reapers selecting namespaces.
+	namespaceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) {
+			impl.GlobalResync(ttlreaperInformer.Informer())
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNs, newNs := oldObj.(*corev1.Namespace), newObj.(*corev1.Namespace)
+			if !equality.Semantic.DeepEqual(oldNs.Labels, newNs.Labels) {
+				impl.GlobalResync(ttlreaperInformer.Informer())
+			}
+		},
+	})
+
+	// Target resources are watched as TTLReapers are reconciled. Evaluate
+	// their objects as they change, and stop watching on shutdown.
+	go c.runObjectWorkers(ctx, objectWorkers)
+	go func() {
+		<-ctx.Done()
+		c.watches.stopAll()
+	}()
+
+	// Expired objects are deleted by a bounded number of workers, tuned
+	// through config-ttlreaper
+	c.setDeleteWorkers(ctx, defaultDeleteWorkers)
+	go func() {
+		<-ctx.Done()
+		c.deleteQueue.ShutDown()
+	}()
+	cmw.Watch(configName, func(cm *corev1.ConfigMap) {
+		cfg, err := newReaperConfigFromConfigMap(cm)
+		if err != nil {
+			logger.Errorw("Ignoring invalid config", zap.Error(err))
+			return
+		}
+		logger.Infow("Applying config",
+			zap.Int(deleteWorkersKey, cfg.deleteWorkers),
+			zap.Bool(targetEventsKey, cfg.targetEvents),
+			zap.Bool(annotateFinishObservedKey, cfg.annotateFinishObserved))
+		c.setDeleteWorkers(ctx, cfg.deleteWorkers)
+		c.targetEvents.Store(cfg.targetEvents)
+		c.annotateFinishObserved.Store(cfg.annotateFinishObserved)
+	})
+	go c.schedule.run(ctx)
+
+	// Deletion counts are written to status on their own, between the
+	// sweeps that also write them
+	go c.runStatusFlushes(ctx)
+
+	// Report the pending deletions and active informers along with the
+	// other metrics
+	if registration, err := c.registerGauges(); err != nil {
+		logger.Errorw("Failed to register gauges", zap.Error(err))
+	} else {
+		go func() {
+			<-ctx.Done()
+			_ = registration.Unregister()
+		}()
+	}
 
-	// Start watching for target resources dynamically based on TTLReaper specs
-	go c.watchTargetResources(ctx, impl)
+	if port := debugPortFromEnv(); port != 0 {
+		go c.serveDebug(ctx, port)
+	}
 
 	return impl
 }
 
-// watchTargetResources dynamically watches ALL resource types that TTLReapers target
-func (c *Reconciler) watchTargetResources(ctx context.Context, impl *controller.Impl) {
-	logger := logging.FromContext(ctx)
-	watchedGVRs := make(map[schema.GroupVersionResource]bool)
-
-	// Check every 30 seconds for new TTLReaper configurations
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// withEventRecorder returns ctx as is if it already has an event recorder,
+// or else with one whose broadcaster records Events through the Kubernetes
+// API until ctx is done.
+//
+// That broadcaster's correlator aggregates Events on the same object with
+// the same reason into one whose count goes up once there are more than a
+// few in ten minutes, and beyond a burst rate limits them per object and
+// reason, so that a big sweep neither floods etcd nor crowds out the Events
+// of other reasons.
+func withEventRecorder(ctx context.Context) context.Context {
+	if controller.GetEventRecorder(ctx) != nil {
+		return ctx
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			ttlreapers, err := c.ttlreaperLister.List(labels.Everything())
-			if err != nil {
-				logger.Errorw("Failed to list TTLReapers for dynamic watching", "error", err)
-				continue
-			}
+	logger := logging.FromContext(ctx)
+	broadcaster := record.NewBroadcaster(record.WithCorrelatorOptions(record.CorrelatorOptions{
+		SpamKeyFunc: eventSpamKey,
+	}))
+	watches := []watch.Interface{
+		broadcaster.StartLogging(logger.Named("event-broadcaster").Infof),
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+			Interface: kubeclient.Get(ctx).CoreV1().Events(""),
+		}),
+	}
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: controllerAgentName})
+	go func() {
+		<-ctx.Done()
+		for _, w := range watches {
+			w.Stop()
+		}
+	}()
+	return controller.WithEventRecorder(ctx, recorder)
+}
 
-			// Collect all unique GVRs that TTLReapers are targeting
-			targetGVRs := make(map[schema.GroupVersionResource][]string)
-			for _, ttlreaper := range ttlreapers {
-				gvr, err := c.parseTargetGVR(ttlreaper.Spec.TargetKind, ttlreaper.Spec.TargetAPIVersion)
-				if err != nil {
-					logger.Errorw("Failed to parse target GVR", "error", err, "ttlreaper", ttlreaper.Name)
-					continue
-				}
-
-				key := fmt.Sprintf("%s/%s", ttlreaper.Spec.TargetKind, ttlreaper.Spec.TargetAPIVersion)
-				targetGVRs[gvr] = append(targetGVRs[gvr], key)
-			}
+// eventSpamKey keys the rate limiting of Events by object and reason.
+func eventSpamKey(event *corev1.Event) string {
+	ref := event.InvolvedObject
+	return strings.Join([]string{
+		event.Source.Component,
+		event.Source.Host,
+		ref.Kind,
+		ref.Namespace,
+		ref.Name,
+		string(ref.UID),
+		ref.APIVersion,
+		event.Type,
+		event.Reason,
+	}, "")
+}
 
-			// Start watching any new GVRs
-			for gvr, targetSpecs := range targetGVRs {
-				if !watchedGVRs[gvr] {
-					c.startWatchingGVR(ctx, impl, gvr, targetSpecs[0])
-					watchedGVRs[gvr] = true
-					logger.Infow("Started watching resource type", "gvr", gvr.String())
-				}
-			}
-		}
+// updateWatches makes the TTLReaper hold on to exactly the given GVRs, for
+// targets with the given scopes, starting, restarting and stopping
+// informers as needed.
+func (r *Reconciler) updateWatches(ctx context.Context, reaperName string, scopes map[schema.GroupVersionResource][]targetScope) {
+	logger := logging.FromContext(ctx)
+	started, stopped := r.watches.set(reaperName, scopes)
+	for _, gvr := range started {
+		logger.Infow("Started watching resource type", "gvr", gvr.String(), "ttlreaper", reaperName)
+	}
+	for _, gvr := range stopped {
+		logger.Infow("Stopped watching resource type no longer targeted", "gvr", gvr.String())
 	}
 }
 
-// startWatchingGVR creates a dynamic informer for the given GVR
-func (c *Reconciler) startWatchingGVR(ctx context.Context, impl *controller.Impl, gvr schema.GroupVersionResource, targetSpec string) {
+// newTargetInformer creates a dynamic informer for the objects of the given
+// GVR in the namespace, or in all of them for metav1.NamespaceAll, that the
+// selector matches. Its cache becomes the source of target objects once
+// synced, and every object added or updated is queued to be evaluated on
+// its own.
+func (r *Reconciler) newTargetInformer(ctx context.Context, gvr schema.GroupVersionResource, namespace string, selector labels.Selector) cache.SharedIndexInformer {
+	// The reflector's options carry the resource version to resume from,
+	// the paging of lists and watch timeouts; only filter on top of them
+	client := r.resourceClient(gvr, namespace)
 	dynamicInformer := cache.NewSharedIndexInformer(
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-				return c.dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+				options.LabelSelector = selector.String()
+				return client.List(ctx, options)
 			},
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-				return c.dynamicClient.Resource(gvr).Watch(ctx, metav1.ListOptions{})
+				options.LabelSelector = selector.String()
+				options.AllowWatchBookmarks = true
+				return client.Watch(ctx, options)
 			},
 		},
 		&unstructured.Unstructured{},
 		controller.GetResyncPeriod(ctx),
-		cache.Indexers{},
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
 	)
 
-	// When target resource changes, enqueue all TTLReapers that target it
+	// The reflector retries failed lists and watches with backoff. A resource
+	// that is gone, e.g. because its CRD was deleted, may have been replaced
+	// by another version, so have the reapers watching it resolve theirs again.
+	logger := logging.FromContext(ctx).With(zap.String("gvr", gvr.String()), zap.String("namespace", namespace))
+	_ = dynamicInformer.SetWatchErrorHandlerWithContext(func(ctx context.Context, reflector *cache.Reflector, err error) {
+		if errors.IsNotFound(err) {
+			logger.Warnw("Watched resource type is no longer served, retrying with backoff", zap.Error(err))
+			r.resolver.reset()
+			for _, name := range r.watches.reapersOf(gvr) {
+				r.enqueueKey(types.NamespacedName{Name: name})
+			}
+		}
+		cache.DefaultWatchErrorHandler(ctx, reflector, err)
+	})
+
 	dynamicInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			if u, ok := obj.(*unstructured.Unstructured); ok {
-				c.enqueueTargetingTTLReapers(ctx, impl, u.GetKind(), u.GetAPIVersion())
-			}
+			r.enqueueObject(gvr, obj)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			if u, ok := newObj.(*unstructured.Unstructured); ok {
-				c.enqueueTargetingTTLReapers(ctx, impl, u.GetKind(), u.GetAPIVersion())
+			r.enqueueObject(gvr, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			// Nothing left to delete
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				r.schedule.cancelObject(u.GetUID())
 			}
 		},
 	})
 
-	// Start the dynamic informer
-	go dynamicInformer.Run(ctx.Done())
-}
-
-// enqueueTargetingTTLReapers finds all TTLReapers that target the given kind/apiVersion and enqueues them
-func (c *Reconciler) enqueueTargetingTTLReapers(ctx context.Context, impl *controller.Impl, targetKind, targetAPIVersion string) {
-	logger := logging.FromContext(ctx)
-
-	ttlreapers, err := c.ttlreaperLister.List(labels.Everything())
-	if err != nil {
-		logger.Errorw("Failed to list TTLReapers", "error", err)
-		return
-	}
-
-	for _, ttlreaper := range ttlreapers {
-		if ttlreaper.Spec.TargetKind == targetKind && ttlreaper.Spec.TargetAPIVersion == targetAPIVersion {
-			impl.Enqueue(ttlreaper)
-		}
-	}
-}
-
-// parseTargetGVR converts targetKind and targetAPIVersion to GroupVersionResource
-func (c *Reconciler) parseTargetGVR(targetKind, targetAPIVersion string) (schema.GroupVersionResource, error) {
-	// Parse API version (e.g., "workflows.example.com/v1" -> group="workflows.example.com", version="v1")
-	parts := strings.Split(targetAPIVersion, "/")
-	if len(parts) != 2 {
-		return schema.GroupVersionResource{}, fmt.Errorf("invalid targetAPIVersion format: %s", targetAPIVersion)
-	}
-
-	group := parts[0]
-	version := parts[1]
-
-	// Convert Kind to plural resource name (basic pluralization)
-	resource := strings.ToLower(targetKind) + "s"
-
-	return schema.GroupVersionResource{
-		Group:    group,
-		Version:  version,
-		Resource: resource,
-	}, nil
+	return dynamicInformer
 }
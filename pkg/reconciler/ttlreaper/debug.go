@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// debugPortEnvKey is the environment variable holding the port of the
+	// debug endpoint, which is only served when it is set
+	debugPortEnvKey = "DEBUG_PORT"
+
+	// debugHost is the only address the debug endpoint listens on, so that
+	// it is only reachable from within the pod, e.g. through port-forward
+	debugHost = "127.0.0.1"
+
+	// watchesPath lists the active target watches
+	watchesPath = "/debug/watches"
+)
+
+// debugPortFromEnv returns the port of the debug endpoint, 0 if it is
+// disabled.
+func debugPortFromEnv() int {
+	if port, err := strconv.Atoi(os.Getenv(debugPortEnvKey)); err == nil && port > 0 {
+		return port
+	}
+	return 0
+}
+
+// serveDebug serves the debug endpoint on the given port until ctx is done.
+func (r *Reconciler) serveDebug(ctx context.Context, port int) {
+	logger := logging.FromContext(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(watchesPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(r.watches.describe()); err != nil {
+			logger.Warnw("Failed to write active watches", zap.Error(err))
+		}
+	})
+	server := &http.Server{
+		Addr:              net.JoinHostPort(debugHost, strconv.Itoa(port)),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	logger.Infow("Serving debug endpoint", zap.String("address", server.Addr), zap.String("path", watchesPath))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Errorw("Debug endpoint failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+// deletionErrorClass tells how a failed deletion is handled.
+type deletionErrorClass string
+
+const (
+	// deletionErrorTransient failures, e.g. throttling or timeouts, are
+	// retried with backoff, up to maxDeletionRetries times before they are
+	// held back until the next sweep
+	deletionErrorTransient deletionErrorClass = "transient"
+	// deletionErrorForbidden failures, including admission webhooks
+	// denying the deletion, are held back until the next sweep
+	deletionErrorForbidden deletionErrorClass = "forbidden"
+	// deletionErrorPermanent failures are requests the API server will
+	// never accept, and are held back until the next sweep too
+	deletionErrorPermanent deletionErrorClass = "permanent"
+)
+
+// maxDeletionRetries is how many times a deletion that failed transiently
+// is retried before it is held back until the next sweep.
+const maxDeletionRetries = 5
+
+// classifyDeletionError tells whether retrying a failed deletion may help.
+func classifyDeletionError(err error) deletionErrorClass {
+	switch {
+	case errors.IsForbidden(err), errors.IsUnauthorized(err):
+		return deletionErrorForbidden
+	case errors.IsBadRequest(err), errors.IsInvalid(err), errors.IsMethodNotSupported(err),
+		errors.IsNotAcceptable(err), errors.IsUnsupportedMediaType(err):
+		return deletionErrorPermanent
+	default:
+		return deletionErrorTransient
+	}
+}
+
+// enqueueDeletion queues an expired deletion for the delete workers.
+func (r *Reconciler) enqueueDeletion(d *scheduledDeletion) {
+	r.deleteQueue.Add(d.key())
+}
+
+// setDeleteWorkers changes how many deletions run at once, starting
+// workers as needed. Surplus ones stop after their current deletion.
+func (r *Reconciler) setDeleteWorkers(ctx context.Context, workers int) {
+	r.deleteWorkersMutex.Lock()
+	defer r.deleteWorkersMutex.Unlock()
+
+	r.deleteWorkers = workers
+	for r.runningDeleteWorkers < r.deleteWorkers {
+		r.runningDeleteWorkers++
+		go r.runDeleteWorker(ctx)
+	}
+}
+
+// runDeleteWorker runs queued deletions until the queue is shut down or
+// there are more workers than configured.
+func (r *Reconciler) runDeleteWorker(ctx context.Context) {
+	for {
+		r.deleteWorkersMutex.Lock()
+		if r.runningDeleteWorkers > r.deleteWorkers {
+			r.runningDeleteWorkers--
+			r.deleteWorkersMutex.Unlock()
+			return
+		}
+		r.deleteWorkersMutex.Unlock()
+
+		if !r.processNextDeletion(ctx) {
+			return
+		}
+	}
+}
+
+// processNextDeletion runs the next queued deletion, queueing it again with
+// backoff if it failed transiently. It returns false once the queue is shut
+// down.
+func (r *Reconciler) processNextDeletion(ctx context.Context) bool {
+	key, shutdown := r.deleteQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.deleteQueue.Done(key)
+
+	d := r.schedule.due(key)
+	if d == nil {
+		// Cancelled, or rescheduled, since it was queued
+		r.deleteQueue.Forget(key)
+		return true
+	}
+	if r.reapScheduled(ctx, d) {
+		r.deleteQueue.AddRateLimited(key)
+		return true
+	}
+	r.deleteQueue.Forget(key)
+	r.schedule.finish(d)
+	return true
+}
+
+// reapScheduled runs an expired deletion for the reaper as it is now: one
+// deleted since has nothing left to delete, and one turned into a dry run
+// only reports it. It returns whether the deletion should be retried.
+func (r *Reconciler) reapScheduled(ctx context.Context, d *scheduledDeletion) (retry bool) {
+	logger := logging.FromContext(ctx).With(
+		zap.String("ttlreaper", d.reaperName),
+		zap.String("resource", d.ref.name),
+		zap.String("kind", d.kind),
+		zap.String("namespace", d.ref.namespace),
+		zap.String("reason", string(d.reason)))
+
+	if !r.leads(d.reaperName) {
+		// Demoted while the deletion was about to run
+		return false
+	}
+	reaper, err := r.ttlreaperLister.Get(d.reaperName)
+	if err != nil {
+		logger.Debugw("Dropping deletion of a TTLReaper that is gone")
+		return false
+	}
+	reaper = reaper.DeepCopy()
+	reaper.SetDefaults(ctx)
+	if reaper.Spec.DryRun {
+		r.reapResource(ctx, reaper, d)
+		return false
+	}
+
+	// Only delete the object as it was evaluated: not one recreated with the
+	// same name since, nor one that changed in a way that may spare it
+	err = r.resourceClient(d.ref.gvr, d.ref.namespace).Delete(ctx, d.ref.name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &d.uid, ResourceVersion: &d.resourceVersion},
+	})
+	switch {
+	case errors.IsNotFound(err):
+		logger.Debugw("Resource already deleted")
+		return false
+	case errors.IsConflict(err):
+		// Evaluate it again as it is now. Until the cache has the change,
+		// that would only schedule the same deletion again; the informer
+		// queues the object once it does.
+		logger.Infow("Resource changed since it was evaluated, not deleting it")
+		if item, exists, err := r.watches.get(d.ref.gvr, d.ref.namespace, d.ref.name); err == nil && exists &&
+			item.GetResourceVersion() != d.resourceVersion {
+			r.objectQueue.Add(d.ref)
+		}
+		return false
+	case err != nil:
+		class := classifyDeletionError(err)
+		retries := r.deleteQueue.NumRequeues(d.key())
+		retry = class == deletionErrorTransient && retries < maxDeletionRetries
+		logger.Errorw("❌ Failed to delete resource", zap.String("errorClass", string(class)),
+			zap.Int("retries", retries), zap.Bool("retry", retry), zap.Error(err))
+		if retries == 0 {
+			// Only the first failure is reported, not each of its retries
+			r.recordDeletionFailure(ctx, reaper, d, class, err)
+		}
+		if !retry {
+			// Keep reconciles from scheduling it again right away
+			r.schedule.hold(d, r.schedule.now().Add(fullSweepInterval))
+		}
+		return retry
+	}
+
+	logger.Infow("✅ Successfully deleted resource")
+	r.recorder.Eventf(reaper, corev1.EventTypeNormal, "Reaped",
+		"Deleted %s %s (%s)", d.ref.gvr.Resource, objectName(d.object()), d.reason)
+	r.recordDeletion(ctx, d)
+	return false
+}
+
+// recordDeletionFailure reports a failed deletion with a Warning Event on
+// the reaper, and counts it in the metrics and towards the reaper's status.
+// It is called once per deletion that fails, however often it is retried.
+func (r *Reconciler) recordDeletionFailure(ctx context.Context, reaper *v1alpha1.TTLReaper, d *scheduledDeletion, class deletionErrorClass, err error) {
+	verdict := "will retry a few times before the next sweep"
+	if class != deletionErrorTransient {
+		verdict = "not retrying before the next sweep"
+	}
+	r.recorder.Eventf(reaper, corev1.EventTypeWarning, "ReapFailed",
+		"Failed to delete %s %s (%s), %s: %v", d.ref.gvr.Resource, objectName(d.object()), class, verdict, err)
+
+	deletionFailures.Add(ctx, 1, metric.WithAttributes(
+		reaperAttr.String(reaper.Name),
+		resourceAttr.String(d.ref.gvr.String()),
+		namespaceAttr.String(d.ref.namespace),
+		outcomeAttr.String(string(d.outcome)),
+		errorClassAttr.String(string(class))))
+
+	r.recordDeletions(reaper.Name, deletionCounts{failed: 1})
+}
+
+// runStatusFlushes writes the deletion counts of queued reapers to their
+// status until ctx is done.
+func (r *Reconciler) runStatusFlushes(ctx context.Context) {
+	defer r.statusQueue.ShutDown()
+	go func() {
+		for r.processNextStatusFlush(ctx) {
+		}
+	}()
+	<-ctx.Done()
+}
+
+// processNextStatusFlush writes the deletion counts of the next queued
+// reaper to its status, queueing it again with backoff if that failed. It
+// returns false once the queue is shut down.
+func (r *Reconciler) processNextStatusFlush(ctx context.Context) bool {
+	reaperName, shutdown := r.statusQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.statusQueue.Done(reaperName)
+
+	if err := r.flushDeletions(ctx, reaperName); err != nil {
+		logging.FromContext(ctx).Errorw("Failed to record deletions in TTLReaper status",
+			zap.String("ttlreaper", reaperName),
+			zap.Error(err))
+		r.statusQueue.AddRateLimited(reaperName)
+		return true
+	}
+	r.statusQueue.Forget(reaperName)
+	return true
+}
+
+// flushDeletions adds the deletion counts not yet written to the reaper's
+// status, updating only its status: the targets are left to the next
+// reconcile.
+func (r *Reconciler) flushDeletions(ctx context.Context, reaperName string) error {
+	if !r.leads(reaperName) {
+		// Written by the next reconcile once promoted again
+		return nil
+	}
+	reaper, err := r.ttlreaperLister.Get(reaperName)
+	if errors.IsNotFound(err) {
+		r.takeDeletions(reaperName)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	deletions := r.takeDeletions(reaperName)
+	if deletions == (deletionCounts{}) {
+		// Already written by a reconcile
+		return nil
+	}
+	status := reaper.Status.DeepCopy()
+	deletions.add(status)
+	if err := r.updateStatus(ctx, reaper, status); err != nil {
+		// Keep the deletions around for the next attempt
+		r.recordDeletions(reaperName, deletions)
+		return err
+	}
+	return nil
+}
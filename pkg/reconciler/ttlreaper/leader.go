@@ -0,0 +1,83 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/reconciler"
+)
+
+// promote takes over the TTLReapers of a bucket we became the leader of.
+// Pending deletions only live in memory, so each reaper is reconciled
+// again: its sweep of the informer caches schedules them anew.
+func (r *Reconciler) promote(ctx context.Context, bkt reconciler.Bucket, enq func(reconciler.Bucket, types.NamespacedName)) error {
+	reapers, err := r.ttlreaperLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list TTLReapers: %w", err)
+	}
+	promoted := 0
+	for _, reaper := range reapers {
+		key := types.NamespacedName{Name: reaper.Name}
+		if bkt.Has(key) {
+			enq(bkt, key)
+			promoted++
+		}
+	}
+	logging.FromContext(ctx).Infow("👑 Became leader, rebuilding deletion schedule",
+		zap.String("bucket", bkt.Name()),
+		zap.Int("ttlreapers", promoted))
+	return nil
+}
+
+// demote lets go of the TTLReapers of a bucket we are no longer the leader
+// of: their pending deletions, observations and watches are the new
+// leader's now.
+func (r *Reconciler) demote(ctx context.Context, bkt reconciler.Bucket) {
+	demoted := 0
+	for _, name := range r.schedule.reapers() {
+		if bkt.Has(types.NamespacedName{Name: name}) {
+			r.schedule.cancelReaper(name)
+			demoted++
+		}
+	}
+	for _, tracker := range []*observationTracker{r.completions, r.dryRunReports, r.evaluationFailures} {
+		for _, name := range tracker.reapers() {
+			if bkt.Has(types.NamespacedName{Name: name}) {
+				tracker.forget(name)
+			}
+		}
+	}
+	for _, name := range r.watches.reapers() {
+		if bkt.Has(types.NamespacedName{Name: name}) {
+			r.updateWatches(ctx, name, nil)
+		}
+	}
+	logging.FromContext(ctx).Infow("Lost leadership, cancelled pending deletions",
+		zap.String("bucket", bkt.Name()),
+		zap.Int("ttlreapers", demoted))
+}
+
+// leads reports whether we are the leader for the TTLReaper.
+func (r *Reconciler) leads(reaperName string) bool {
+	return r.IsLeaderFor(types.NamespacedName{Name: reaperName})
+}
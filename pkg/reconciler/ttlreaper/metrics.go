@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Metrics are recorded through the global OpenTelemetry meter provider,
+// which sharedmain sets up from the observability config.
+var meter = otel.Meter("github.com/infernus01/knative-demo/pkg/reconciler/ttlreaper")
+
+var (
+	reaperAttr     = attribute.Key("ttlreaper")
+	resourceAttr   = attribute.Key("resource")
+	namespaceAttr  = attribute.Key("namespace")
+	reasonAttr     = attribute.Key("reason")
+	outcomeAttr    = attribute.Key("outcome")
+	errorClassAttr = attribute.Key("error_class")
+)
+
+var maxAgeDeletions = mustInt64Counter(meter.Int64Counter("ttlreaper.maxage.deletions",
+	metric.WithDescription("Number of objects deleted for outliving a TTLReaper's maxAge"),
+	metric.WithUnit("{object}")))
+
+var deletionFailures = mustInt64Counter(meter.Int64Counter("ttlreaper.deletion.failures",
+	metric.WithDescription("Number of deletions of an object that failed, by error class, not counting their retries"),
+	metric.WithUnit("{deletion}")))
+
+var reapedObjects = mustInt64Counter(meter.Int64Counter("ttlreaper.reaped",
+	metric.WithDescription("Number of objects deleted, by reason and outcome"),
+	metric.WithUnit("{object}")))
+
+var reconcileErrors = mustInt64Counter(meter.Int64Counter("ttlreaper.reconcile.errors",
+	metric.WithDescription("Number of TTLReaper reconciles that failed"),
+	metric.WithUnit("{reconcile}")))
+
+var reapingLag = mustFloat64Histogram(meter.Float64Histogram("ttlreaper.reaping.lag",
+	metric.WithDescription("Time from when an object expired to when it was deleted"),
+	metric.WithUnit("s"),
+	metric.WithExplicitBucketBoundaries(0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600)))
+
+func mustInt64Counter(counter metric.Int64Counter, err error) metric.Int64Counter {
+	if err != nil {
+		panic(err)
+	}
+	return counter
+}
+
+func mustFloat64Histogram(histogram metric.Float64Histogram, err error) metric.Float64Histogram {
+	if err != nil {
+		panic(err)
+	}
+	return histogram
+}
+
+// registerGauges reports the pending deletions, by reaper and resource, and
+// the informers watching target resources, by resource, whenever metrics
+// are collected.
+func (r *Reconciler) registerGauges() (metric.Registration, error) {
+	pending, err := meter.Int64ObservableGauge("ttlreaper.deletions.pending",
+		metric.WithDescription("Number of scheduled deletions not done yet"),
+		metric.WithUnit("{object}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pending deletions gauge: %w", err)
+	}
+	informers, err := meter.Int64ObservableGauge("ttlreaper.informers.active",
+		metric.WithDescription("Number of informers watching target resources"),
+		metric.WithUnit("{informer}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create active informers gauge: %w", err)
+	}
+
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for key, n := range r.schedule.pendingCounts() {
+			o.ObserveInt64(pending, int64(n), metric.WithAttributes(
+				reaperAttr.String(key.reaperName),
+				resourceAttr.String(key.gvr.String())))
+		}
+		for gvr, n := range r.watches.informerCounts() {
+			o.ObserveInt64(informers, int64(n), metric.WithAttributes(
+				resourceAttr.String(gvr.String())))
+		}
+		return nil
+	}, pending, informers)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+// targetNamespaces returns, sorted, the namespaces a reaper of a namespaced
+// kind monitors: its targetNamespace, or else every namespace its
+// namespaceSelector matches that is neither excluded nor a system namespace
+// it didn't explicitly include. Namespaces come from the shared informer
+// cache rather than the API server.
+func (r *Reconciler) targetNamespaces(spec *v1alpha1.TargetSettings) ([]string, error) {
+	if spec.TargetNamespace != "" {
+		if v1alpha1.IsSystemNamespace(spec.TargetNamespace) && !spec.IncludesSystemNamespace(spec.TargetNamespace) {
+			return nil, nil
+		}
+		return []string{spec.TargetNamespace}, nil
+	}
+
+	selector := labels.Everything()
+	if spec.NamespaceSelector != nil {
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector: %w", err)
+		}
+	}
+	nsList, err := r.namespaceLister.List(selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	excluded := make(map[string]bool, len(spec.ExcludedNamespaces))
+	for _, ns := range spec.ExcludedNamespaces {
+		excluded[ns] = true
+	}
+
+	namespaces := make([]string, 0, len(nsList))
+	for _, ns := range nsList {
+		switch {
+		case excluded[ns.Name]:
+		case v1alpha1.IsSystemNamespace(ns.Name) && !spec.IncludesSystemNamespace(ns.Name):
+		default:
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// selectsNamespace reports whether a reaper of a namespaced kind monitors
+// the namespace, by the same rules as targetNamespaces.
+func (r *Reconciler) selectsNamespace(spec *v1alpha1.TargetSettings, namespace string) (bool, error) {
+	switch {
+	case v1alpha1.IsSystemNamespace(namespace) && !spec.IncludesSystemNamespace(namespace):
+		return false, nil
+	case spec.TargetNamespace != "":
+		return namespace == spec.TargetNamespace, nil
+	case slices.Contains(spec.ExcludedNamespaces, namespace):
+		return false, nil
+	case spec.NamespaceSelector == nil:
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+	ns, err := r.namespaceLister.Get(namespace)
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"knative.dev/pkg/logging"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+// objectRef identifies a target object in the object queue.
+type objectRef struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+func (o objectRef) String() string {
+	if o.namespace == "" {
+		return fmt.Sprintf("%s %s", o.gvr.String(), o.name)
+	}
+	return fmt.Sprintf("%s %s/%s", o.gvr.String(), o.namespace, o.name)
+}
+
+// enqueueObject queues a target object to be evaluated against the reapers
+// targeting it.
+func (r *Reconciler) enqueueObject(gvr schema.GroupVersionResource, obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	r.objectQueue.Add(objectRef{gvr: gvr, namespace: u.GetNamespace(), name: u.GetName()})
+}
+
+// runObjectWorkers evaluates queued target objects with the given number of
+// workers until ctx is done.
+func (r *Reconciler) runObjectWorkers(ctx context.Context, workers int) {
+	defer r.objectQueue.ShutDown()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for r.processNextObject(ctx) {
+			}
+		}()
+	}
+	<-ctx.Done()
+}
+
+// processNextObject evaluates the next queued target object. It returns
+// false once the queue is shut down.
+func (r *Reconciler) processNextObject(ctx context.Context) bool {
+	ref, shutdown := r.objectQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.objectQueue.Done(ref)
+
+	if err := r.reconcileObject(ctx, ref); err != nil {
+		logging.FromContext(ctx).Errorw("Failed to evaluate target object",
+			zap.Stringer("object", ref),
+			zap.Error(err))
+		r.objectQueue.AddRateLimited(ref)
+		return true
+	}
+	r.objectQueue.Forget(ref)
+	return true
+}
+
+// reconcileObject evaluates one target object, as found in the informer
+// cache, against every target of every TTLReaper we lead. Its deletion is
+// scheduled by the reapers it matches and cancelled by the others; the
+// reapers' status is left to their own reconciles.
+func (r *Reconciler) reconcileObject(ctx context.Context, ref objectRef) error {
+	logger := logging.FromContext(ctx).With(zap.Stringer("object", ref))
+
+	item, exists, err := r.watches.get(ref.gvr, ref.namespace, ref.name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		// Deleted since it was queued, or no longer watched; the delete
+		// handler or the next sweep takes care of it
+		return nil
+	}
+
+	reapers, err := r.ttlreaperLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list TTLReapers: %w", err)
+	}
+	for _, reaper := range reapers {
+		if !r.leads(reaper.Name) {
+			continue
+		}
+		reaper = reaper.DeepCopy()
+		reaper.SetDefaults(ctx)
+		policies, invalid, err := r.policies.get(ctx, reaper)
+		if invalid != nil || err != nil {
+			// Reported on the reaper by its own reconcile
+			continue
+		}
+		matched := false
+		for i := range reaper.Spec.Targets {
+			target := &reaper.Spec.Targets[i]
+			matches, err := r.targetMatches(target, policies[i], ref.gvr, item)
+			if err != nil {
+				return err
+			}
+			if !matches {
+				continue
+			}
+			matched = true
+			logger.Debugw("Evaluating target object",
+				zap.String("ttlreaper", reaper.Name),
+				zap.String("target", target.Name))
+			if err := r.reconcileTargetObject(ctx, reaper, target, policies[i], ref.gvr, item); err != nil {
+				return err
+			}
+		}
+		if !matched {
+			// E.g. relabelled out of the reaper's selectors
+			r.schedule.cancel(reaper.Name, item.GetUID())
+		}
+	}
+	return nil
+}
+
+// targetMatches reports whether the object of gvr belongs to the target:
+// the target's kind resolves to gvr, and its namespaces and label selector
+// include the object.
+func (r *Reconciler) targetMatches(target *v1alpha1.TargetSpec, policy *reapPolicy, gvr schema.GroupVersionResource, item *unstructured.Unstructured) (bool, error) {
+	mapping, err := r.resolver.resolve(target.APIVersion, target.Kind)
+	if err != nil {
+		// Reported on the reaper by its own reconcile
+		return false, nil
+	}
+	if mapping.Resource != gvr || !policy.selector.Matches(labels.Set(item.GetLabels())) {
+		return false, nil
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return true, nil
+	}
+	return r.selectsNamespace(&target.TargetSettings, item.GetNamespace())
+}
+
+// reconcileTargetObject schedules the deletion of one object of the target.
+// Objects of targets with retention limits are evaluated along with the
+// rest of their group, whose oldest members they may push out.
+func (r *Reconciler) reconcileTargetObject(ctx context.Context, reaper *v1alpha1.TTLReaper, target *v1alpha1.TargetSpec, policy *reapPolicy, gvr schema.GroupVersionResource, item *unstructured.Unstructured) error {
+	// Status is only written by full reconciles, so what this finds is
+	// thrown away
+	var result reapResult
+
+	obj := r.evaluate(ctx, reaper, policy, gvr, item)
+	if obj == nil {
+		return nil
+	}
+
+	if group, ok := groupOf(policy, item); ok && obj.finished {
+		items, err := r.listTargets(ctx, gvr, item.GetNamespace(), policy.selector)
+		if err != nil {
+			return err
+		}
+		objects := make([]*targetObject, 0, len(items))
+		for _, sibling := range items {
+			if g, ok := groupOf(policy, sibling); !ok || g != group {
+				continue
+			}
+			if sibling.GetUID() == item.GetUID() {
+				objects = append(objects, obj)
+			} else if o := r.evaluate(ctx, reaper, policy, gvr, sibling); o != nil {
+				objects = append(objects, o)
+			}
+		}
+		if r.prune(ctx, reaper, target, policy, objects, gvr, &result)[obj] {
+			return nil
+		}
+	}
+
+	r.scheduleObject(ctx, reaper, target, policy, obj, gvr, &result)
+	if result.newlyScheduled > 0 {
+		deletion := result.scheduled[0]
+		r.recorder.Eventf(reaper, corev1.EventTypeNormal, "ReapScheduled",
+			"Scheduled the deletion of %s %s at %s (%s)", gvr.Resource, objectName(item),
+			deletion.ExpirationTime.UTC().Format(time.RFC3339), deletion.Reason)
+	}
+	return nil
+}
+
+// groupOf returns the retention group of the object, and false when the
+// policy has no retention limits or the object belongs to no group.
+func groupOf(policy *reapPolicy, item *unstructured.Unstructured) (string, bool) {
+	if policy.retention == nil {
+		return "", false
+	}
+	return policy.retention.groupKey(item)
+}
+
+// listTargets returns the objects of gvr in the namespace, or of all of
+// them for metav1.NamespaceAll, that selector matches. They come from the
+// informer cache once it has synced, and from the API server before that
+// or when the watch doesn't cover them, e.g. right after the target
+// changed. Objects from the cache are shared and must not be modified.
+func (r *Reconciler) listTargets(ctx context.Context, gvr schema.GroupVersionResource, namespace string, selector labels.Selector) ([]*unstructured.Unstructured, error) {
+	if items, ok, err := r.watches.list(gvr, namespace, selector); ok {
+		return items, err
+	}
+
+	list, err := r.resourceClient(gvr, namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*unstructured.Unstructured, 0, len(list.Items))
+	for i := range list.Items {
+		items = append(items, &list.Items[i])
+	}
+	return items, nil
+}
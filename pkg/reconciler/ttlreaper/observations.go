@@ -0,0 +1,103 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// observationTracker remembers, per TTLReaper, when the controller first
+// observed something about target objects: that a finished object carries
+// no finish time of its own, for reapers whose missingFinishTime policy is
+// FirstObserved until that is recorded on the object, that a dry-run
+// reaper would have deleted an object, or that evaluating an object failed.
+// It lives in memory only.
+type observationTracker struct {
+	mu sync.Mutex
+	// observations per TTLReaper name, by object UID
+	observations map[string]map[types.UID]*observation
+}
+
+type observation struct {
+	first time.Time
+	last  time.Time
+}
+
+func newObservationTracker() *observationTracker {
+	return &observationTracker{
+		observations: make(map[string]map[types.UID]*observation),
+	}
+}
+
+// observe records that the reaper observed the object at now and returns
+// when it first did.
+func (t *observationTracker) observe(reaperName string, uid types.UID, now time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	byUID, ok := t.observations[reaperName]
+	if !ok {
+		byUID = make(map[types.UID]*observation)
+		t.observations[reaperName] = byUID
+	}
+	o, ok := byUID[uid]
+	if !ok {
+		o = &observation{first: now}
+		byUID[uid] = o
+	}
+	o.last = now
+	return o.first
+}
+
+// forgetUnseenSince drops the reaper's observations of objects it hasn't
+// seen since the given time, i.e. objects that are gone or no longer match.
+func (t *observationTracker) forgetUnseenSince(reaperName string, since time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	byUID := t.observations[reaperName]
+	for uid, o := range byUID {
+		if o.last.Before(since) {
+			delete(byUID, uid)
+		}
+	}
+	if len(byUID) == 0 {
+		delete(t.observations, reaperName)
+	}
+}
+
+// reapers returns the names of the reapers with observations.
+func (t *observationTracker) reapers() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.observations))
+	for name := range t.observations {
+		names = append(names, name)
+	}
+	return names
+}
+
+// forget drops every observation of the reaper.
+func (t *observationTracker) forget(reaperName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.observations, reaperName)
+}
@@ -0,0 +1,429 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+	"github.com/infernus01/knative-demo/pkg/celexpr"
+	"github.com/infernus01/knative-demo/pkg/fieldpath"
+)
+
+// reapPolicy is the compiled form of the parts of a target's settings that
+// decide which objects it matches and when each of them expires. It is
+// safe for concurrent use.
+type reapPolicy struct {
+	// selector matches the target's objects by label
+	selector labels.Selector
+
+	ttlField   *fieldpath.Path
+	defaultTTL *time.Duration
+	maxTTL     *time.Duration
+
+	ttlAfterSuccess    *time.Duration
+	ttlAfterFailure    *time.Duration
+	ttlAfterCompletion *time.Duration
+
+	// finishedWhen overrides the built-in completion heuristics when set
+	finishedWhen *celexpr.Program
+
+	// outcomeRules are tried in order before the built-in classification
+	outcomeRules []outcomeRule
+
+	finishTimeSources []finishTimeSource
+	missingFinishTime v1alpha1.MissingFinishTimePolicy
+
+	// retention is nil unless the reaper sets history limits
+	retention *retentionPolicy
+
+	// maxAge is nil unless the reaper opted into deleting objects by age
+	maxAge *time.Duration
+}
+
+// outcomeRule classifies the objects its expression matches as outcome.
+type outcomeRule struct {
+	outcome    v1alpha1.Outcome
+	expression *celexpr.Program
+}
+
+// finishTimeSource is the compiled form of a v1alpha1.FinishTimeSource.
+// Exactly one of field and expression is set.
+type finishTimeSource struct {
+	field      *fieldpath.Path
+	expression *celexpr.Program
+}
+
+func newReapPolicy(spec *v1alpha1.TargetSettings) (*reapPolicy, error) {
+	ttlField, err := fieldpath.Parse(spec.TTLFieldPath)
+	if err != nil {
+		return nil, fmt.Errorf("ttlFieldPath: %w", err)
+	}
+	policy := &reapPolicy{
+		selector:          labels.Everything(),
+		ttlField:          ttlField,
+		missingFinishTime: spec.MissingFinishTime,
+	}
+	if spec.LabelSelector != nil {
+		policy.selector, err = metav1.LabelSelectorAsSelector(spec.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("labelSelector: %w", err)
+		}
+	}
+	if spec.DefaultTTL != nil {
+		policy.defaultTTL = &spec.DefaultTTL.Duration
+	}
+	if spec.MaxTTL != nil {
+		policy.maxTTL = &spec.MaxTTL.Duration
+	}
+	if spec.TTLAfterSuccess != nil {
+		policy.ttlAfterSuccess = &spec.TTLAfterSuccess.Duration
+	}
+	if spec.TTLAfterFailure != nil {
+		policy.ttlAfterFailure = &spec.TTLAfterFailure.Duration
+	}
+	if spec.TTLAfterCompletion != nil {
+		policy.ttlAfterCompletion = &spec.TTLAfterCompletion.Duration
+	}
+	if spec.FinishedWhen != "" {
+		policy.finishedWhen, err = celexpr.Compile(spec.FinishedWhen, cel.BoolType)
+		if err != nil {
+			return nil, fmt.Errorf("finishedWhen: %w", err)
+		}
+	}
+	if spec.Outcomes != nil {
+		for _, rule := range []struct {
+			field      string
+			outcome    v1alpha1.Outcome
+			expression string
+		}{
+			{"cancelledWhen", v1alpha1.OutcomeCancelled, spec.Outcomes.CancelledWhen},
+			{"failedWhen", v1alpha1.OutcomeFailed, spec.Outcomes.FailedWhen},
+			{"succeededWhen", v1alpha1.OutcomeSucceeded, spec.Outcomes.SucceededWhen},
+		} {
+			if rule.expression == "" {
+				continue
+			}
+			program, err := celexpr.Compile(rule.expression, cel.BoolType)
+			if err != nil {
+				return nil, fmt.Errorf("outcomes.%s: %w", rule.field, err)
+			}
+			policy.outcomeRules = append(policy.outcomeRules, outcomeRule{outcome: rule.outcome, expression: program})
+		}
+	}
+	for i, source := range spec.FinishTimeSources {
+		var compiled finishTimeSource
+		if source.FieldPath != "" {
+			compiled.field, err = fieldpath.Parse(source.FieldPath)
+		} else {
+			compiled.expression, err = celexpr.Compile(source.Expression, nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("finishTimeSources[%d]: %w", i, err)
+		}
+		policy.finishTimeSources = append(policy.finishTimeSources, compiled)
+	}
+	if spec.Retention != nil {
+		policy.retention = newRetentionPolicy(spec.Retention)
+	}
+	if spec.MaxAge != nil {
+		policy.maxAge = &spec.MaxAge.Age.Duration
+	}
+	return policy, nil
+}
+
+// policyCache keeps the validated and compiled policies of the targets of
+// each TTLReaper, so that evaluating single objects neither validates nor
+// compiles them over and over. A reaper's entry is replaced when its
+// generation changes.
+type policyCache struct {
+	mu sync.Mutex
+	// entries by TTLReaper name
+	entries map[string]policyCacheEntry
+}
+
+type policyCacheEntry struct {
+	uid        types.UID
+	generation int64
+	policies   []*reapPolicy
+	// invalid holds what is wrong with the reaper's spec, and err why its
+	// policies failed to compile
+	invalid *apis.FieldError
+	err     error
+}
+
+func newPolicyCache() *policyCache {
+	return &policyCache{
+		entries: make(map[string]policyCacheEntry),
+	}
+}
+
+// get returns the policies of the reaper's targets, in the order of
+// spec.targets, once the reaper's spec passed validation. invalid is set
+// when it doesn't, and err when the policies fail to compile. The reaper
+// must be defaulted.
+func (c *policyCache) get(ctx context.Context, reaper *v1alpha1.TTLReaper) (policies []*reapPolicy, invalid *apis.FieldError, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[reaper.Name]
+	if !ok || entry.uid != reaper.UID || entry.generation != reaper.Generation {
+		entry = policyCacheEntry{uid: reaper.UID, generation: reaper.Generation}
+		// The admission webhook rejects invalid specs, but reapers created
+		// before it was installed may still be invalid
+		if entry.invalid = reaper.Validate(ctx); entry.invalid == nil {
+			entry.policies, entry.err = compilePolicies(reaper)
+		}
+		c.entries[reaper.Name] = entry
+	}
+	return entry.policies, entry.invalid, entry.err
+}
+
+// compilePolicies compiles the policies of the reaper's targets.
+func compilePolicies(reaper *v1alpha1.TTLReaper) ([]*reapPolicy, error) {
+	policies := make([]*reapPolicy, len(reaper.Spec.Targets))
+	for i := range reaper.Spec.Targets {
+		policy, err := newReapPolicy(&reaper.Spec.Targets[i].TargetSettings)
+		if err != nil {
+			return nil, fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		policies[i] = policy
+	}
+	return policies, nil
+}
+
+// forget drops the policies of the reaper.
+func (c *policyCache) forget(reaperName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, reaperName)
+}
+
+// finishTime returns when obj finished, as told by the first finish time
+// source that yields a time. found is false when none does.
+func (p *reapPolicy) finishTime(obj *unstructured.Unstructured) (finishTime time.Time, found bool, err error) {
+	for i, source := range p.finishTimeSources {
+		if source.expression != nil {
+			finishTime, found, err = source.expression.EvalTime(obj.Object)
+		} else {
+			finishTime, found, err = lookupTime(source.field, obj)
+		}
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("finishTimeSources[%d]: %w", i, err)
+		}
+		if found {
+			return finishTime, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// lookupTime reads an RFC 3339 timestamp, with or without fractional
+// seconds, from the field at path.
+func lookupTime(path *fieldpath.Path, obj *unstructured.Unstructured) (time.Time, bool, error) {
+	value, found, err := path.Lookup(obj.Object)
+	if err != nil || !found || value == nil {
+		return time.Time{}, false, err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%s has unsupported type %T", path, value)
+	}
+	if s == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, true, nil
+}
+
+// outcome reports whether obj has reached a terminal state and how it ended.
+// The reaper's outcome rules are tried first. Failing those, the finishedWhen
+// expression, if there is one, decides whether obj finished, and the
+// built-in heuristics decide the rest.
+func (p *reapPolicy) outcome(obj *unstructured.Unstructured) (outcome v1alpha1.Outcome, finished bool, err error) {
+	for _, rule := range p.outcomeRules {
+		matched, err := rule.expression.EvalBool(obj.Object)
+		if err != nil {
+			return "", false, fmt.Errorf("outcome %s: %w", rule.outcome, err)
+		}
+		if matched {
+			return rule.outcome, true, nil
+		}
+	}
+
+	outcome, finished = classifyOutcome(obj)
+	if p.finishedWhen == nil {
+		return outcome, finished, nil
+	}
+	finished, err = p.finishedWhen.EvalBool(obj.Object)
+	if err != nil || !finished {
+		return "", false, err
+	}
+	if outcome == "" {
+		outcome = v1alpha1.OutcomeUnknown
+	}
+	return outcome, true, nil
+}
+
+// ttl returns the TTL to apply to obj, which ended with the given outcome,
+// and where it came from: the object's TTL annotation or else its own TTL
+// field, either capped at maxTTL, or else the first of the reaper's
+// outcome-specific TTL, ttlAfterCompletion and defaultTTL that is set. found
+// is false when none applies.
+func (p *reapPolicy) ttl(obj *unstructured.Unstructured, outcome v1alpha1.Outcome) (ttl time.Duration, source v1alpha1.TTLSource, found bool, err error) {
+	source = v1alpha1.TTLSourceAnnotation
+	ttl, found, err = annotationTTL(obj)
+	if err == nil && !found {
+		source = v1alpha1.TTLSourceObject
+		ttl, found, err = p.objectTTL(obj)
+	}
+	if err != nil {
+		return 0, "", false, err
+	}
+	if found {
+		if p.maxTTL != nil && ttl > *p.maxTTL {
+			return *p.maxTTL, v1alpha1.TTLSourceMaxTTL, true, nil
+		}
+		return ttl, source, true, nil
+	}
+	switch outcome {
+	case v1alpha1.OutcomeSucceeded:
+		if p.ttlAfterSuccess != nil {
+			return *p.ttlAfterSuccess, v1alpha1.TTLSourceAfterSuccess, true, nil
+		}
+	case v1alpha1.OutcomeFailed, v1alpha1.OutcomeCancelled:
+		if p.ttlAfterFailure != nil {
+			return *p.ttlAfterFailure, v1alpha1.TTLSourceAfterFailure, true, nil
+		}
+	}
+	if p.ttlAfterCompletion != nil {
+		return *p.ttlAfterCompletion, v1alpha1.TTLSourceAfterCompletion, true, nil
+	}
+	if p.defaultTTL != nil {
+		return *p.defaultTTL, v1alpha1.TTLSourceDefault, true, nil
+	}
+	return 0, "", false, nil
+}
+
+// objectTTL returns the TTL the object carries in the policy's TTL field.
+// found is false when the object doesn't set the field.
+func (p *reapPolicy) objectTTL(obj *unstructured.Unstructured) (ttl time.Duration, found bool, err error) {
+	value, found, err := p.ttlField.Lookup(obj.Object)
+	if err != nil || !found {
+		return 0, false, err
+	}
+	ttl, err = parseTTL(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", p.ttlField, err)
+	}
+	return ttl, true, nil
+}
+
+// kept reports whether the object's owner protected it from the reaper.
+func kept(obj *unstructured.Unstructured) bool {
+	return obj.GetAnnotations()[v1alpha1.KeepAnnotation] == "true"
+}
+
+// annotationTTL returns the TTL set by the object's TTL annotation. found is
+// false when the object isn't annotated.
+func annotationTTL(obj *unstructured.Unstructured) (ttl time.Duration, found bool, err error) {
+	value, found := obj.GetAnnotations()[v1alpha1.TTLAnnotation]
+	if !found {
+		return 0, false, nil
+	}
+	ttl, err = parseTTL(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", v1alpha1.TTLAnnotation, err)
+	}
+	return ttl, true, nil
+}
+
+// expiresAt returns the deadline set by the object's expires-at annotation.
+// found is false when the object isn't annotated.
+func expiresAt(obj *unstructured.Unstructured) (deadline time.Time, found bool, err error) {
+	value, found := obj.GetAnnotations()[v1alpha1.ExpiresAtAnnotation]
+	if !found {
+		return time.Time{}, false, nil
+	}
+	deadline, err = time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s: %w", v1alpha1.ExpiresAtAnnotation, err)
+	}
+	return deadline, true, nil
+}
+
+// finishObservedAt returns when the controller first observed the object
+// finished, as recorded in its finish-observed-at annotation. found is
+// false when the object isn't annotated.
+func finishObservedAt(obj *unstructured.Unstructured) (observed time.Time, found bool, err error) {
+	value, found := obj.GetAnnotations()[v1alpha1.FinishObservedAtAnnotation]
+	if !found {
+		return time.Time{}, false, nil
+	}
+	observed, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s: %w", v1alpha1.FinishObservedAtAnnotation, err)
+	}
+	return observed, true, nil
+}
+
+// parseTTL converts a TTL field value into a duration. Numbers are seconds,
+// strings are either a number of seconds or a Go duration such as "36h".
+func parseTTL(value interface{}) (time.Duration, error) {
+	var ttl time.Duration
+	switch v := value.(type) {
+	case int64:
+		ttl = time.Duration(v) * time.Second
+	case int:
+		ttl = time.Duration(v) * time.Second
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("TTL %v is not a whole number of seconds", v)
+		}
+		ttl = time.Duration(v) * time.Second
+	case string:
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+			ttl = time.Duration(seconds) * time.Second
+		} else if d, err := time.ParseDuration(v); err == nil {
+			ttl = d
+		} else {
+			return 0, fmt.Errorf("TTL %q is neither a number of seconds nor a duration", v)
+		}
+	default:
+		return 0, fmt.Errorf("TTL has unsupported type %T", value)
+	}
+
+	if ttl < 0 {
+		return 0, fmt.Errorf("TTL %v is negative", ttl)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    time.Duration
+		wantErr bool
+	}{{
+		name:  "int64 seconds",
+		value: int64(300),
+		want:  5 * time.Minute,
+	}, {
+		name:  "int seconds",
+		value: 60,
+		want:  time.Minute,
+	}, {
+		name:  "whole float seconds",
+		value: float64(90),
+		want:  90 * time.Second,
+	}, {
+		name:    "fractional float seconds",
+		value:   1.5,
+		wantErr: true,
+	}, {
+		name:  "string seconds",
+		value: "3600",
+		want:  time.Hour,
+	}, {
+		name:  "duration string",
+		value: "36h",
+		want:  36 * time.Hour,
+	}, {
+		name:  "zero",
+		value: "0",
+		want:  0,
+	}, {
+		name:    "negative seconds",
+		value:   int64(-1),
+		wantErr: true,
+	}, {
+		name:    "negative duration",
+		value:   "-1h",
+		wantErr: true,
+	}, {
+		name:    "garbage string",
+		value:   "soon",
+		wantErr: true,
+	}, {
+		name:    "unsupported type",
+		value:   true,
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseTTL(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseTTL(%v) error = %v, wantErr %v", test.value, err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("parseTTL(%v) = %v, want %v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReapPolicyTTL(t *testing.T) {
+	hour := &metav1.Duration{Duration: time.Hour}
+	day := &metav1.Duration{Duration: 24 * time.Hour}
+
+	tests := []struct {
+		name       string
+		settings   v1alpha1.TargetSettings
+		objectTTL  interface{}
+		outcome    v1alpha1.Outcome
+		want       time.Duration
+		wantSource v1alpha1.TTLSource
+		wantFound  bool
+	}{{
+		name: "no TTL",
+	}, {
+		name:       "object TTL",
+		settings:   v1alpha1.TargetSettings{DefaultTTL: day, TTLAfterSuccess: day},
+		objectTTL:  int64(60),
+		outcome:    v1alpha1.OutcomeSucceeded,
+		want:       time.Minute,
+		wantSource: v1alpha1.TTLSourceObject,
+		wantFound:  true,
+	}, {
+		name:       "object TTL above maxTTL",
+		settings:   v1alpha1.TargetSettings{MaxTTL: hour},
+		objectTTL:  "36h",
+		want:       time.Hour,
+		wantSource: v1alpha1.TTLSourceMaxTTL,
+		wantFound:  true,
+	}, {
+		name:       "ttlAfterSuccess",
+		settings:   v1alpha1.TargetSettings{DefaultTTL: day, TTLAfterSuccess: hour, TTLAfterFailure: day},
+		outcome:    v1alpha1.OutcomeSucceeded,
+		want:       time.Hour,
+		wantSource: v1alpha1.TTLSourceAfterSuccess,
+		wantFound:  true,
+	}, {
+		name:       "ttlAfterFailure for cancelled objects",
+		settings:   v1alpha1.TargetSettings{DefaultTTL: day, TTLAfterSuccess: day, TTLAfterFailure: hour},
+		outcome:    v1alpha1.OutcomeCancelled,
+		want:       time.Hour,
+		wantSource: v1alpha1.TTLSourceAfterFailure,
+		wantFound:  true,
+	}, {
+		name:       "ttlAfterCompletion without an outcome TTL",
+		settings:   v1alpha1.TargetSettings{DefaultTTL: day, TTLAfterSuccess: day, TTLAfterCompletion: hour},
+		outcome:    v1alpha1.OutcomeUnknown,
+		want:       time.Hour,
+		wantSource: v1alpha1.TTLSourceAfterCompletion,
+		wantFound:  true,
+	}, {
+		name:       "defaultTTL",
+		settings:   v1alpha1.TargetSettings{DefaultTTL: hour, MaxTTL: day, TTLAfterFailure: day},
+		outcome:    v1alpha1.OutcomeSucceeded,
+		want:       time.Hour,
+		wantSource: v1alpha1.TTLSourceDefault,
+		wantFound:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.settings.TTLFieldPath = ".spec.ttl"
+			policy, err := newReapPolicy(&test.settings)
+			if err != nil {
+				t.Fatalf("newReapPolicy() = %v", err)
+			}
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			if test.objectTTL != nil {
+				obj.Object["spec"] = map[string]interface{}{"ttl": test.objectTTL}
+			}
+			got, source, found, err := policy.ttl(obj, test.outcome)
+			if err != nil {
+				t.Fatalf("ttl() = %v", err)
+			}
+			if got != test.want || source != test.wantSource || found != test.wantFound {
+				t.Errorf("ttl() = (%v, %q, %v), want (%v, %q, %v)",
+					got, source, found, test.want, test.wantSource, test.wantFound)
+			}
+		})
+	}
+}
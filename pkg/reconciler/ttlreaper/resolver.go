@@ -0,0 +1,158 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+// targetResolver maps the apiVersion and kind of a TTLReaper's targets to the
+// resource and scope actually served by the API server.
+//
+// Discovery results are cached in memory and only fetched again after reset,
+// which the controller calls whenever CustomResourceDefinitions change, or
+// when a reconcile can't resolve a kind. The latter backs off per kind, and
+// never refreshes more often than every minResetInterval across kinds.
+type targetResolver struct {
+	mapper *restmapper.DeferredDiscoveryRESTMapper
+
+	mu        sync.Mutex
+	lastReset time.Time
+	// missing holds the kinds that failed to resolve after a refresh
+	missing map[schema.GroupVersionKind]*missingKind
+}
+
+// missingKind tracks when a kind that failed to resolve may refresh the
+// discovery results again.
+type missingKind struct {
+	backoff   time.Duration
+	nextReset time.Time
+}
+
+const (
+	// minResetInterval bounds how often failing to resolve kinds refreshes
+	// the discovery results.
+	minResetInterval = 30 * time.Second
+	// maxResetBackoff bounds how long a kind that keeps failing to resolve
+	// waits before refreshing them again.
+	maxResetBackoff = fullSweepInterval
+)
+
+func newTargetResolver(client discovery.DiscoveryInterface) *targetResolver {
+	return &targetResolver{
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(client)),
+		lastReset: time.Now(),
+		missing:   make(map[schema.GroupVersionKind]*missingKind),
+	}
+}
+
+// resolve returns the REST mapping for the given apiVersion and kind from
+// the cached discovery results. Kinds the API server does not serve yield
+// an error for which meta.IsNoMatchError returns true.
+func (t *targetResolver) resolve(apiVersion, kind string) (*meta.RESTMapping, error) {
+	gvk, err := parseKind(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+	return t.mapping(gvk)
+}
+
+// resolveFresh is resolve for reconciles: a kind that isn't found refreshes
+// the discovery results, in case an API server serving it was added since.
+func (t *targetResolver) resolveFresh(apiVersion, kind string) (*meta.RESTMapping, error) {
+	gvk, err := parseKind(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := t.mapping(gvk)
+	if meta.IsNoMatchError(err) && t.resetFor(gvk) {
+		mapping, err = t.mapping(gvk)
+		t.resolved(gvk, !meta.IsNoMatchError(err))
+	}
+	return mapping, err
+}
+
+func parseKind(apiVersion, kind string) (schema.GroupVersionKind, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("invalid targetAPIVersion %q: %w", apiVersion, err)
+	}
+	return gv.WithKind(kind), nil
+}
+
+func (t *targetResolver) mapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := t.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s %s: %w", gvk.GroupVersion(), gvk.Kind, err)
+	}
+	return mapping, nil
+}
+
+// reset drops the cached discovery information so that newly installed
+// kinds become resolvable.
+func (t *targetResolver) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mapper.Reset()
+	t.lastReset = time.Now()
+	clear(t.missing)
+}
+
+// resetFor resets the discovery results for a kind that failed to resolve,
+// unless the kind is backing off or they were reset in the last
+// minResetInterval, and returns whether it did.
+func (t *targetResolver) resetFor(gvk schema.GroupVersionKind) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if m, ok := t.missing[gvk]; ok && now.Before(m.nextReset) {
+		return false
+	}
+	if now.Sub(t.lastReset) < minResetInterval {
+		return false
+	}
+	t.mapper.Reset()
+	t.lastReset = now
+	return true
+}
+
+// resolved records whether a kind resolved after resetting the discovery
+// results for it, doubling its backoff while it doesn't.
+func (t *targetResolver) resolved(gvk schema.GroupVersionKind, found bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if found {
+		delete(t.missing, gvk)
+		return
+	}
+	m, ok := t.missing[gvk]
+	if !ok {
+		m = &missingKind{backoff: minResetInterval}
+		t.missing[gvk] = m
+	} else {
+		m.backoff = min(2*m.backoff, maxResetBackoff)
+	}
+	m.nextReset = time.Now().Add(m.backoff)
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+// targetObject is a target object along with what the reaper worked out
+// about it.
+type targetObject struct {
+	item *unstructured.Unstructured
+
+	// finished is true once the object reached a terminal state, which
+	// outcome describes
+	finished bool
+	outcome  v1alpha1.Outcome
+
+	// finishTime is when a finished object finished. It is the creation
+	// time, for ordering only, when hasFinishTime is false.
+	finishTime    time.Time
+	hasFinishTime bool
+}
+
+// retentionPolicy is the compiled form of a v1alpha1.RetentionSpec.
+type retentionPolicy struct {
+	label     string
+	ownerKind string
+
+	successfulLimit *int32
+	failedLimit     *int32
+}
+
+func newRetentionPolicy(spec *v1alpha1.RetentionSpec) *retentionPolicy {
+	return &retentionPolicy{
+		label:           spec.GroupBy.Label,
+		ownerKind:       spec.GroupBy.OwnerKind,
+		successfulLimit: spec.SuccessfulHistoryLimit,
+		failedLimit:     spec.FailedHistoryLimit,
+	}
+}
+
+// groupKey returns the group obj belongs to within its namespace, or false
+// when it doesn't belong to any.
+func (p *retentionPolicy) groupKey(obj *unstructured.Unstructured) (string, bool) {
+	if p.label != "" {
+		value, ok := obj.GetLabels()[p.label]
+		return value, ok
+	}
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == p.ownerKind {
+			return string(ref.UID), true
+		}
+	}
+	return "", false
+}
+
+// limit returns the history limit for the outcome, or false when objects
+// with that outcome are kept regardless.
+func (p *retentionPolicy) limit(outcome v1alpha1.Outcome) (int, bool) {
+	var limit *int32
+	switch outcome {
+	case v1alpha1.OutcomeSucceeded:
+		limit = p.successfulLimit
+	case v1alpha1.OutcomeFailed, v1alpha1.OutcomeCancelled:
+		limit = p.failedLimit
+	}
+	if limit == nil {
+		return 0, false
+	}
+	return int(*limit), true
+}
+
+// excess returns the finished objects, all from one namespace, that exceed
+// their group's history limits: all but the newest ones of each group and outcome.
+func (p *retentionPolicy) excess(objects []*targetObject) []*targetObject {
+	type bucket struct {
+		group string
+		// succeeded or failed; cancelled objects count as failed
+		failed bool
+	}
+	buckets := make(map[bucket][]*targetObject)
+	for _, obj := range objects {
+		if _, limited := p.limit(obj.outcome); !obj.finished || !limited {
+			continue
+		}
+		group, ok := p.groupKey(obj.item)
+		if !ok {
+			continue
+		}
+		b := bucket{group: group, failed: obj.outcome != v1alpha1.OutcomeSucceeded}
+		buckets[b] = append(buckets[b], obj)
+	}
+
+	var excess []*targetObject
+	for _, objs := range buckets {
+		limit, _ := p.limit(objs[0].outcome)
+		if len(objs) <= limit {
+			continue
+		}
+		// Newest first
+		sort.SliceStable(objs, func(i, j int) bool {
+			return objs[i].finishTime.After(objs[j].finishTime)
+		})
+		excess = append(excess, objs[limit:]...)
+	}
+	return excess
+}
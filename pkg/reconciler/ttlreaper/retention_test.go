@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+func TestRetentionPolicyExcess(t *testing.T) {
+	limit := func(n int32) *int32 { return &n }
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// object returns an object of the group, or of none when group is
+	// empty, that finished age minutes before base
+	object := func(name, group string, outcome v1alpha1.Outcome, age int) *targetObject {
+		item := &unstructured.Unstructured{}
+		item.SetName(name)
+		if group != "" {
+			item.SetLabels(map[string]string{"pipeline": group})
+		}
+		return &targetObject{
+			item:          item,
+			finished:      outcome != "",
+			outcome:       outcome,
+			finishTime:    base.Add(-time.Duration(age) * time.Minute),
+			hasFinishTime: true,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		spec    v1alpha1.RetentionSpec
+		objects []*targetObject
+		want    []string
+	}{{
+		name: "keeps the newest successful objects",
+		spec: v1alpha1.RetentionSpec{SuccessfulHistoryLimit: limit(2)},
+		objects: []*targetObject{
+			object("old", "build", v1alpha1.OutcomeSucceeded, 30),
+			object("new", "build", v1alpha1.OutcomeSucceeded, 10),
+			object("oldest", "build", v1alpha1.OutcomeSucceeded, 40),
+			object("newer", "build", v1alpha1.OutcomeSucceeded, 20),
+		},
+		want: []string{"old", "oldest"},
+	}, {
+		name: "groups are limited independently",
+		spec: v1alpha1.RetentionSpec{SuccessfulHistoryLimit: limit(1)},
+		objects: []*targetObject{
+			object("build-old", "build", v1alpha1.OutcomeSucceeded, 20),
+			object("build-new", "build", v1alpha1.OutcomeSucceeded, 10),
+			object("test", "test", v1alpha1.OutcomeSucceeded, 30),
+		},
+		want: []string{"build-old"},
+	}, {
+		name: "cancelled objects count as failed",
+		spec: v1alpha1.RetentionSpec{
+			SuccessfulHistoryLimit: limit(1),
+			FailedHistoryLimit:     limit(1),
+		},
+		objects: []*targetObject{
+			object("succeeded", "build", v1alpha1.OutcomeSucceeded, 30),
+			object("failed", "build", v1alpha1.OutcomeFailed, 20),
+			object("cancelled", "build", v1alpha1.OutcomeCancelled, 10),
+		},
+		want: []string{"failed"},
+	}, {
+		name: "outcomes without a limit are kept",
+		spec: v1alpha1.RetentionSpec{FailedHistoryLimit: limit(0)},
+		objects: []*targetObject{
+			object("succeeded", "build", v1alpha1.OutcomeSucceeded, 30),
+			object("unknown", "build", v1alpha1.OutcomeUnknown, 25),
+			object("failed", "build", v1alpha1.OutcomeFailed, 20),
+		},
+		want: []string{"failed"},
+	}, {
+		name: "unfinished and ungrouped objects are kept",
+		spec: v1alpha1.RetentionSpec{SuccessfulHistoryLimit: limit(0)},
+		objects: []*targetObject{
+			object("running", "build", "", 30),
+			object("ungrouped", "", v1alpha1.OutcomeSucceeded, 20),
+			object("done", "build", v1alpha1.OutcomeSucceeded, 10),
+		},
+		want: []string{"done"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.spec.GroupBy.Label = "pipeline"
+			var got []string
+			for _, obj := range newRetentionPolicy(&test.spec).excess(test.objects) {
+				got = append(got, obj.item.GetName())
+			}
+			sort.Strings(got)
+			sort.Strings(test.want)
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("excess() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
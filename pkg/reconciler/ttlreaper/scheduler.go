@@ -0,0 +1,368 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+// deletionKey identifies the pending deletion of a target object by a
+// reaper.
+type deletionKey struct {
+	reaperName string
+	uid        types.UID
+}
+
+// scheduledDeletion is the pending deletion of a target object by a reaper.
+// It only references the object, as it was when evaluated.
+type scheduledDeletion struct {
+	reaperName      string
+	ref             objectRef
+	kind            string
+	uid             types.UID
+	resourceVersion string
+	reason          v1alpha1.DeletionReason
+	outcome         v1alpha1.Outcome
+
+	// expiration is when the object is to be deleted
+	expiration time.Time
+	// scheduledAt is when the deletion was last (re)scheduled
+	scheduledAt time.Time
+	// heldUntil is set once the deletion failed in a way retrying won't
+	// fix: until then, it is neither run nor scheduled again
+	heldUntil time.Time
+
+	// index in the heap, -1 once expired
+	index int
+}
+
+func (d *scheduledDeletion) key() deletionKey {
+	return deletionKey{reaperName: d.reaperName, uid: d.uid}
+}
+
+func newScheduledDeletion(reaperName string, resource *unstructured.Unstructured, gvr schema.GroupVersionResource, reason v1alpha1.DeletionReason, outcome v1alpha1.Outcome, expiration, now time.Time) *scheduledDeletion {
+	return &scheduledDeletion{
+		reaperName:      reaperName,
+		ref:             objectRef{gvr: gvr, namespace: resource.GetNamespace(), name: resource.GetName()},
+		kind:            resource.GetKind(),
+		uid:             resource.GetUID(),
+		resourceVersion: resource.GetResourceVersion(),
+		reason:          reason,
+		outcome:         outcome,
+		expiration:      expiration,
+		scheduledAt:     now,
+	}
+}
+
+// object returns a stand-in for the object carrying what deleting it needs.
+func (d *scheduledDeletion) object() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(d.ref.gvr.GroupVersion().String())
+	obj.SetKind(d.kind)
+	obj.SetNamespace(d.ref.namespace)
+	obj.SetName(d.ref.name)
+	obj.SetUID(d.uid)
+	obj.SetResourceVersion(d.resourceVersion)
+	return obj
+}
+
+// deletionHeap orders pending deletions by expiration.
+type deletionHeap []*scheduledDeletion
+
+func (h deletionHeap) Len() int           { return len(h) }
+func (h deletionHeap) Less(i, j int) bool { return h[i].expiration.Before(h[j].expiration) }
+func (h deletionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *deletionHeap) Push(x interface{}) {
+	d := x.(*scheduledDeletion)
+	d.index = len(*h)
+	*h = append(*h, d)
+}
+
+func (h *deletionHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	d := old[n-1]
+	old[n-1] = nil
+	d.index = -1
+	*h = old[:n-1]
+	return d
+}
+
+// deletionSchedule holds the pending deletions of every reaper, each reaper
+// owning at most one per target object UID. A single goroutine waits for
+// the soonest to expire and hands expired ones over to be deleted; they
+// stay pending until finished, so that cancelling them still works while
+// they are retried or held back.
+type deletionSchedule struct {
+	clock clock.Clock
+
+	mu       sync.Mutex
+	queue    deletionHeap
+	byReaper map[string]map[types.UID]*scheduledDeletion
+	wake     chan struct{}
+
+	// expired hands over a deletion that expired
+	expired func(*scheduledDeletion)
+}
+
+func newDeletionSchedule(clock clock.Clock, expired func(*scheduledDeletion)) *deletionSchedule {
+	return &deletionSchedule{
+		clock:    clock,
+		byReaper: make(map[string]map[types.UID]*scheduledDeletion),
+		wake:     make(chan struct{}, 1),
+		expired:  expired,
+	}
+}
+
+// now returns the current time of the schedule's clock.
+func (s *deletionSchedule) now() time.Time {
+	return s.clock.Now()
+}
+
+// poke has the scheduler look at the soonest deletion again.
+func (s *deletionSchedule) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// schedule adds the deletion, replacing the one the reaper had pending for
+// the object. It returns false when that one was already due at the same
+// time for the same reason.
+func (s *deletionSchedule) schedule(d *scheduledDeletion) (changed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byUID, ok := s.byReaper[d.reaperName]
+	if !ok {
+		byUID = make(map[types.UID]*scheduledDeletion)
+		s.byReaper[d.reaperName] = byUID
+	}
+	changed = true
+	if existing, ok := byUID[d.uid]; ok {
+		changed = !existing.expiration.Equal(d.expiration) || existing.reason != d.reason
+		if existing.index >= 0 {
+			heap.Remove(&s.queue, existing.index)
+		}
+	}
+	byUID[d.uid] = d
+	heap.Push(&s.queue, d)
+	if d.index == 0 {
+		s.poke()
+	}
+	return changed
+}
+
+// keep tells whether the reaper's deletion of the object, as of
+// resourceVersion, expired already and is to be left alone rather than
+// scheduled again: it is due, being retried, or held back after failing.
+// A deletion kept counts as scheduled now.
+func (s *deletionSchedule) keep(reaperName string, uid types.UID, resourceVersion string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.byReaper[reaperName][uid]
+	if !ok || d.resourceVersion != resourceVersion || d.expiration.After(now) {
+		return false
+	}
+	if !d.heldUntil.IsZero() && !d.heldUntil.After(now) {
+		// Held long enough, try again
+		return false
+	}
+	d.scheduledAt = now
+	return true
+}
+
+// hold keeps an expired deletion that failed from running, and from being
+// scheduled again, until the given time.
+func (s *deletionSchedule) hold(d *scheduledDeletion, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byReaper[d.reaperName][d.uid] == d {
+		d.heldUntil = until
+	}
+}
+
+// remove drops a pending deletion. s.mu must be held.
+func (s *deletionSchedule) remove(byUID map[types.UID]*scheduledDeletion, d *scheduledDeletion) {
+	if d.index >= 0 {
+		heap.Remove(&s.queue, d.index)
+	}
+	delete(byUID, d.uid)
+	if len(byUID) == 0 {
+		delete(s.byReaper, d.reaperName)
+	}
+}
+
+// cancel drops the reaper's pending deletion of the object, if any.
+func (s *deletionSchedule) cancel(reaperName string, uid types.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byUID := s.byReaper[reaperName]
+	if d, ok := byUID[uid]; ok {
+		s.remove(byUID, d)
+	}
+}
+
+// cancelObject drops every reaper's pending deletion of the object, e.g.
+// because it is gone.
+func (s *deletionSchedule) cancelObject(uid types.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, byUID := range s.byReaper {
+		if d, ok := byUID[uid]; ok {
+			s.remove(byUID, d)
+		}
+	}
+}
+
+// cancelReaper drops every pending deletion of the reaper.
+func (s *deletionSchedule) cancelReaper(reaperName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.byReaper[reaperName] {
+		if d.index >= 0 {
+			heap.Remove(&s.queue, d.index)
+		}
+	}
+	delete(s.byReaper, reaperName)
+}
+
+// cancelUnscheduledSince drops the reaper's pending deletions that were not
+// scheduled again since the given time, i.e. of objects that are gone or no
+// longer match.
+func (s *deletionSchedule) cancelUnscheduledSince(reaperName string, since time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byUID := s.byReaper[reaperName]
+	for _, d := range byUID {
+		if d.scheduledAt.Before(since) {
+			s.remove(byUID, d)
+		}
+	}
+}
+
+// reapers returns the names of the reapers with pending deletions.
+func (s *deletionSchedule) reapers() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.byReaper))
+	for name := range s.byReaper {
+		names = append(names, name)
+	}
+	return names
+}
+
+// pendingKey groups pending deletions in metrics.
+type pendingKey struct {
+	reaperName string
+	gvr        schema.GroupVersionResource
+}
+
+// pendingCounts counts the pending deletions by reaper and resource.
+func (s *deletionSchedule) pendingCounts() map[pendingKey]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[pendingKey]int)
+	for reaperName, byUID := range s.byReaper {
+		for _, d := range byUID {
+			counts[pendingKey{reaperName: reaperName, gvr: d.ref.gvr}]++
+		}
+	}
+	return counts
+}
+
+// due returns the deletion with the given key if it expired and is still
+// pending, and isn't held back, or nil.
+func (s *deletionSchedule) due(key deletionKey) *scheduledDeletion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.byReaper[key.reaperName][key.uid]; ok && d.index < 0 && d.heldUntil.IsZero() {
+		return d
+	}
+	return nil
+}
+
+// finish drops an expired deletion once done with, unless it was
+// rescheduled in the meantime or is held back.
+func (s *deletionSchedule) finish(d *scheduledDeletion) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byUID := s.byReaper[d.reaperName]
+	if byUID[d.uid] == d && d.heldUntil.IsZero() {
+		s.remove(byUID, d)
+	}
+}
+
+// run hands deletions over as they expire, until ctx is done.
+func (s *deletionSchedule) run(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		now := s.clock.Now()
+		var expired []*scheduledDeletion
+		for s.queue.Len() > 0 && !s.queue[0].expiration.After(now) {
+			expired = append(expired, heap.Pop(&s.queue).(*scheduledDeletion))
+		}
+		var timer clock.Timer
+		var next <-chan time.Time
+		if s.queue.Len() > 0 {
+			timer = s.clock.NewTimer(s.queue[0].expiration.Sub(now))
+			next = timer.C()
+		}
+		s.mu.Unlock()
+
+		for _, d := range expired {
+			s.expired(d)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-next:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+const testReaper = "reaper"
+
+var testGVR = schema.GroupVersionResource{Group: "tekton.dev", Version: "v1", Resource: "pipelineruns"}
+
+// testSchedule runs a deletion schedule on a fake clock and collects the
+// deletions it hands over.
+type testSchedule struct {
+	*deletionSchedule
+	clock   *clocktesting.FakeClock
+	expired chan *scheduledDeletion
+}
+
+func newTestSchedule(t *testing.T) *testSchedule {
+	t.Helper()
+	ts := &testSchedule{
+		clock:   clocktesting.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		expired: make(chan *scheduledDeletion, 10),
+	}
+	ts.deletionSchedule = newDeletionSchedule(ts.clock, func(d *scheduledDeletion) {
+		ts.expired <- d
+	})
+	return ts
+}
+
+// start runs the scheduler until the test ends.
+func (ts *testSchedule) start(t *testing.T) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go ts.run(ctx)
+}
+
+// deletion returns the deletion of the object with the given UID and
+// resourceVersion, expiring after the given delay, as if scheduled now.
+func (ts *testSchedule) deletion(uid types.UID, resourceVersion string, after time.Duration) *scheduledDeletion {
+	obj := &unstructured.Unstructured{}
+	obj.SetKind("PipelineRun")
+	obj.SetNamespace("default")
+	obj.SetName(string(uid))
+	obj.SetUID(uid)
+	obj.SetResourceVersion(resourceVersion)
+	now := ts.clock.Now()
+	return newScheduledDeletion(testReaper, obj, testGVR, v1alpha1.DeletionReasonTTLExpired,
+		v1alpha1.OutcomeSucceeded, now.Add(after), now)
+}
+
+// step moves the clock forward once the scheduler waits for the next
+// deletion to expire.
+func (ts *testSchedule) step(t *testing.T, d time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(wait.ForeverTestTimeout)
+	for !ts.clock.HasWaiters() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the scheduler to wait")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ts.clock.Step(d)
+}
+
+// next returns the next deletion handed over.
+func (ts *testSchedule) next(t *testing.T) *scheduledDeletion {
+	t.Helper()
+	select {
+	case d := <-ts.expired:
+		return d
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatal("Timed out waiting for a deletion to expire")
+		return nil
+	}
+}
+
+// expectNone checks that no deletion was handed over.
+func (ts *testSchedule) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case d := <-ts.expired:
+		t.Fatalf("Deletion of %s expired unexpectedly", d.uid)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// soonest returns the deletion next to expire, if any, and how many are
+// waiting to.
+func (ts *testSchedule) soonest() (*scheduledDeletion, int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.queue.Len() == 0 {
+		return nil, 0
+	}
+	return ts.queue[0], ts.queue.Len()
+}
+
+// pending returns how many deletions the reaper has pending.
+func (ts *testSchedule) pending() int {
+	return ts.pendingCounts()[pendingKey{reaperName: testReaper, gvr: testGVR}]
+}
+
+func TestDeletionScheduleExpiresInOrder(t *testing.T) {
+	ts := newTestSchedule(t)
+	ts.schedule(ts.deletion("c", "1", 3*time.Minute))
+	ts.schedule(ts.deletion("a", "1", time.Minute))
+	ts.schedule(ts.deletion("b", "1", 2*time.Minute))
+	ts.start(t)
+
+	for _, want := range []types.UID{"a", "b", "c"} {
+		ts.step(t, time.Minute)
+		if got := ts.next(t); got.uid != want {
+			t.Errorf("Expired %s, want %s", got.uid, want)
+		}
+	}
+	// Expired deletions stay pending until finished
+	if got := ts.pending(); got != 3 {
+		t.Errorf("pending = %d, want 3", got)
+	}
+}
+
+func TestDeletionScheduleWakesForSoonerDeletion(t *testing.T) {
+	ts := newTestSchedule(t)
+	ts.schedule(ts.deletion("later", "1", time.Hour))
+	ts.start(t)
+
+	// Wait for the scheduler to wait for the later deletion
+	ts.step(t, 0)
+	ts.schedule(ts.deletion("sooner", "1", time.Minute))
+	ts.step(t, time.Minute)
+	if got := ts.next(t); got.uid != "sooner" {
+		t.Errorf("Expired %s, want sooner", got.uid)
+	}
+	ts.expectNone(t)
+}
+
+func TestDeletionScheduleReschedule(t *testing.T) {
+	ts := newTestSchedule(t)
+	if changed := ts.schedule(ts.deletion("a", "1", time.Minute)); !changed {
+		t.Error("schedule() of a new deletion = false, want true")
+	}
+	if changed := ts.schedule(ts.deletion("a", "1", time.Minute)); changed {
+		t.Error("schedule() of the same deletion = true, want false")
+	}
+	if changed := ts.schedule(ts.deletion("a", "2", 3*time.Minute)); !changed {
+		t.Error("schedule() of a moved deletion = false, want true")
+	}
+	if _, queued := ts.soonest(); queued != 1 {
+		t.Fatalf("queue holds %d deletions, want 1", queued)
+	}
+	ts.start(t)
+
+	// Only the rescheduled deletion expires, when it is due
+	ts.step(t, time.Minute)
+	ts.expectNone(t)
+	ts.step(t, 2*time.Minute)
+	if got := ts.next(t); got.uid != "a" || got.resourceVersion != "2" {
+		t.Errorf("Expired %s at resourceVersion %s, want a at 2", got.uid, got.resourceVersion)
+	}
+	ts.expectNone(t)
+}
+
+func TestDeletionScheduleCancel(t *testing.T) {
+	ts := newTestSchedule(t)
+	ts.schedule(ts.deletion("a", "1", time.Minute))
+	ts.schedule(ts.deletion("b", "1", time.Minute))
+	ts.schedule(ts.deletion("c", "1", time.Hour))
+	ts.cancel(testReaper, "a")
+	ts.cancelObject("b")
+	ts.start(t)
+
+	ts.step(t, time.Minute)
+	ts.expectNone(t)
+	if soonest, queued := ts.soonest(); queued != 1 || soonest.uid != "c" {
+		t.Errorf("queue holds %d deletions, want only c", queued)
+	}
+
+	ts.cancelReaper(testReaper)
+	if got := ts.pending(); got != 0 {
+		t.Errorf("pending after cancelReaper() = %d, want 0", got)
+	}
+	if _, queued := ts.soonest(); queued != 0 {
+		t.Errorf("queue holds %d deletions, want none", queued)
+	}
+}
+
+func TestDeletionScheduleCancelExpired(t *testing.T) {
+	ts := newTestSchedule(t)
+	ts.schedule(ts.deletion("a", "1", time.Minute))
+	ts.schedule(ts.deletion("b", "1", time.Hour))
+	ts.start(t)
+	ts.step(t, time.Minute)
+	d := ts.next(t)
+
+	// Being retried, out of the heap
+	if got := ts.due(d.key()); got != d {
+		t.Fatalf("due() = %v, want the expired deletion", got)
+	}
+	ts.cancel(testReaper, "a")
+	if got := ts.due(d.key()); got != nil {
+		t.Errorf("due() after cancel() = %v, want nil", got)
+	}
+	if got := ts.pending(); got != 1 {
+		t.Errorf("pending = %d, want 1", got)
+	}
+	if soonest, queued := ts.soonest(); queued != 1 || soonest.uid != "b" {
+		t.Errorf("queue holds %d deletions, want only b", queued)
+	}
+}
+
+func TestDeletionScheduleCancelUnscheduledSince(t *testing.T) {
+	ts := newTestSchedule(t)
+	ts.schedule(ts.deletion("gone", "1", time.Hour))
+	ts.schedule(ts.deletion("kept", "1", time.Hour))
+
+	// A sweep a minute later only schedules kept again
+	ts.clock.Step(time.Minute)
+	sweep := ts.clock.Now()
+	ts.schedule(ts.deletion("kept", "1", time.Hour-time.Minute))
+	ts.cancelUnscheduledSince(testReaper, sweep)
+
+	if got := ts.pending(); got != 1 {
+		t.Fatalf("pending = %d, want 1", got)
+	}
+	if soonest, _ := ts.soonest(); soonest.uid != "kept" {
+		t.Errorf("Pending deletion of %s, want kept", soonest.uid)
+	}
+}
+
+func TestDeletionScheduleFinish(t *testing.T) {
+	ts := newTestSchedule(t)
+	ts.schedule(ts.deletion("a", "1", time.Minute))
+	ts.start(t)
+	ts.step(t, time.Minute)
+	expired := ts.next(t)
+
+	// The object changed while its deletion was running
+	ts.schedule(ts.deletion("a", "2", time.Hour))
+	ts.finish(expired)
+	if got := ts.pending(); got != 1 {
+		t.Fatalf("pending after finishing a rescheduled deletion = %d, want 1", got)
+	}
+	if soonest, _ := ts.soonest(); soonest.resourceVersion != "2" {
+		t.Errorf("Pending deletion at resourceVersion %s, want 2", soonest.resourceVersion)
+	}
+
+	ts.step(t, time.Hour)
+	rescheduled := ts.next(t)
+	ts.finish(rescheduled)
+	if got := ts.pending(); got != 0 {
+		t.Errorf("pending after finishing = %d, want 0", got)
+	}
+}
+
+func TestDeletionScheduleKeepAndHold(t *testing.T) {
+	ts := newTestSchedule(t)
+	ts.schedule(ts.deletion("a", "1", time.Minute))
+
+	if ts.keep(testReaper, "a", "1", ts.clock.Now()) {
+		t.Error("keep() of a deletion not due yet = true, want false")
+	}
+
+	ts.start(t)
+	ts.step(t, time.Minute)
+	d := ts.next(t)
+
+	now := ts.clock.Now()
+	if !ts.keep(testReaper, "a", "1", now) {
+		t.Error("keep() of an expired deletion = false, want true")
+	}
+	if ts.keep(testReaper, "a", "2", now) {
+		t.Error("keep() of a changed object = true, want false")
+	}
+
+	// Held back after failing, it neither runs nor is finished
+	ts.hold(d, now.Add(fullSweepInterval))
+	if got := ts.due(d.key()); got != nil {
+		t.Errorf("due() of a held deletion = %v, want nil", got)
+	}
+	ts.finish(d)
+	if got := ts.pending(); got != 1 {
+		t.Fatalf("pending after finishing a held deletion = %d, want 1", got)
+	}
+	if !ts.keep(testReaper, "a", "1", now.Add(fullSweepInterval-time.Second)) {
+		t.Error("keep() of a held deletion = false, want true")
+	}
+	if ts.keep(testReaper, "a", "1", now.Add(fullSweepInterval)) {
+		t.Error("keep() once held long enough = true, want false")
+	}
+}
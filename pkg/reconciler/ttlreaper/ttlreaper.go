@@ -18,36 +18,112 @@ package ttlreaper
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/reconciler"
 
 	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+	versioned "github.com/infernus01/knative-demo/pkg/generated/clientset/versioned"
 	ttlreaperlister "github.com/infernus01/knative-demo/pkg/generated/listers/clusterops/v1alpha1"
 )
 
 // Reconciler implements controller.Reconciler for TTLReaper resources.
 type Reconciler struct {
+	// Only the leader of a TTLReaper's bucket schedules and deletes its
+	// target objects
+	reconciler.LeaderAwareFuncs
+
 	kubeclientset   kubernetes.Interface
+	clientset       versioned.Interface
 	dynamicClient   dynamic.Interface
 	ttlreaperLister ttlreaperlister.TTLReaperLister
+	namespaceLister corev1listers.NamespaceLister
+
+	// resolver maps target kinds to resources through API discovery
+	resolver *targetResolver
+
+	// enqueueKey re-enqueues a TTLReaper, e.g. when a watched resource is no
+	// longer served
+	enqueueKey func(types.NamespacedName)
+
+	// schedule holds the pending deletions of target objects, deleted at
+	// their exact expiration time (like Jobs)
+	schedule *deletionSchedule
+
+	// Deletions per TTLReaper not yet recorded in its status
+	deletions      map[string]deletionCounts
+	deletionsMutex sync.Mutex
+
+	// statusQueue holds the TTLReapers whose deletion counts are to be
+	// written to their status, without reconciling them
+	statusQueue workqueue.TypedRateLimitingInterface[string]
+
+	// completions remembers when finished objects without a finish time
+	// were first seen finished
+	completions *observationTracker
+
+	// dryRunReports remembers which deletions dry-run reapers already
+	// reported, so each is reported once
+	dryRunReports *observationTracker
+
+	// evaluationFailures remembers which objects the reapers' expressions
+	// failed on, so each failure is reported by an Event once
+	evaluationFailures *observationTracker
+
+	// recorder emits Events on TTLReapers
+	recorder record.EventRecorder
+
+	// watches holds the dynamic informers of the target resources being
+	// watched. Their caches are what target objects are read from.
+	watches *targetWatches
+
+	// deleteQueue holds the expired deletions to be run by the delete
+	// workers, retried with backoff when they fail transiently
+	deleteQueue workqueue.TypedRateLimitingInterface[deletionKey]
+
+	// deleteWorkers is how many delete workers should run, and
+	// runningDeleteWorkers how many do
+	deleteWorkers        int
+	runningDeleteWorkers int
+	deleteWorkersMutex   sync.Mutex
 
-	// Timer management for immediate TTL deletion (like Jobs)
-	timers      map[string]*time.Timer
-	timersMutex sync.RWMutex
+	// objectQueue holds the target objects that changed and have yet to be
+	// evaluated against the reapers targeting them
+	objectQueue workqueue.TypedRateLimitingInterface[objectRef]
+
+	// policies caches the compiled policies of the reapers' targets
+	policies *policyCache
+
+	// targetEvents enables WillBeReaped Events on target objects
+	targetEvents atomic.Bool
+
+	// annotateFinishObserved enables recording on target objects when they
+	// were first observed finished
+	annotateFinishObserved atomic.Bool
 }
 
 // Check that our Reconciler implements Interface
@@ -66,230 +142,860 @@ func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
 	if errors.IsNotFound(err) {
 		// The TTLReaper resource may no longer exist, in which case we stop processing.
 		logger.Info("TTLReaper resource no longer exists")
+		r.completions.forget(key)
+		r.dryRunReports.forget(key)
+		r.evaluationFailures.forget(key)
+		r.policies.forget(key)
+		r.schedule.cancelReaper(key)
+		r.updateWatches(ctx, key, nil)
 		return nil
 	} else if err != nil {
 		return err
 	}
+	if !r.leads(key) {
+		// The leader takes care of it; we pick it up if we get promoted
+		logger.Debug("Not the leader for TTLReaper, skipping")
+		return nil
+	}
 
-	return r.reconcileTTLReaper(ctx, ttlReaper)
+	// Work on a defaulted copy so that reapers admitted before the webhook was
+	// installed behave the same as new ones. This also keeps us from modifying
+	// the informer's copy.
+	ttlReaper = ttlReaper.DeepCopy()
+	ttlReaper.SetDefaults(ctx)
+
+	err = r.reconcileTTLReaper(ctx, ttlReaper)
+	if requeue, _ := controller.IsRequeueKey(err); err != nil && !requeue {
+		class := "transient"
+		if controller.IsPermanentError(err) {
+			class = "permanent"
+		}
+		reconcileErrors.Add(ctx, 1, metric.WithAttributes(
+			reaperAttr.String(key),
+			errorClassAttr.String(class)))
+	}
+	return err
 }
 
 func (r *Reconciler) reconcileTTLReaper(ctx context.Context, reaper *v1alpha1.TTLReaper) error {
 	logger := logging.FromContext(ctx).With(zap.String("ttlreaper", reaper.Name))
 
-	// Validate required fields
-	if reaper.Spec.TargetKind == "" {
-		logger.Error("TargetKind is required")
-		return fmt.Errorf("targetKind is required")
+	status := reaper.Status.DeepCopy()
+	status.InitializeConditions()
+	status.ObservedGeneration = reaper.Generation
+	now := metav1.Now()
+	status.LastProcessedTime = &now
+
+	reconcileErr := r.reconcileTargets(ctx, reaper, status)
+
+	// Scheduled deletions run since the last status update, plus the ones
+	// done by this reconcile
+	deletions := r.takeDeletions(reaper.Name)
+	deletions.add(status)
+
+	if err := r.updateStatus(ctx, reaper, status); err != nil {
+		logger.Errorw("Failed to update TTLReaper status", zap.Error(err))
+		// Keep the deletions around for the next attempt
+		r.recordDeletions(reaper.Name, deletions)
+		if reconcileErr == nil {
+			return err
+		}
 	}
-	if reaper.Spec.TargetAPIVersion == "" {
-		logger.Error("TargetAPIVersion is required")
-		return fmt.Errorf("targetAPIVersion is required")
+	if reconcileErr != nil {
+		return reconcileErr
 	}
 
-	// Parse the API version to get group and version
-	gv, err := schema.ParseGroupVersion(reaper.Spec.TargetAPIVersion)
-	if err != nil {
-		logger.Errorw("Invalid targetAPIVersion", zap.Error(err))
-		return fmt.Errorf("invalid targetAPIVersion: %w", err)
+	// Target objects are evaluated one by one as they change; sweep all of
+	// them again every now and then in case an event was missed
+	return controller.NewRequeueAfter(fullSweepInterval)
+}
+
+// reconcileTargets schedules deletion of every finished target object of the
+// reaper and fills in status with what it found.
+func (r *Reconciler) reconcileTargets(ctx context.Context, reaper *v1alpha1.TTLReaper, status *v1alpha1.TTLReaperStatus) error {
+	logger := logging.FromContext(ctx).With(zap.String("ttlreaper", reaper.Name))
+
+	policies, invalid, err := r.policies.get(ctx, reaper)
+	switch {
+	case invalid != nil:
+		// Retrying won't fix an invalid spec
+		logger.Errorw("Invalid TTLReaper spec", zap.Error(invalid))
+		status.MarkPolicyInvalid("InvalidSpec", "%v", invalid)
+		r.schedule.cancelReaper(reaper.Name)
+		r.updateWatches(ctx, reaper.Name, nil)
+		return controller.NewPermanentError(invalid)
+	case err != nil:
+		logger.Errorw("Failed to compile TTLReaper policy", zap.Error(err))
+		status.MarkPolicyInvalid("CompileFailed", "%v", err)
+		r.schedule.cancelReaper(reaper.Name)
+		r.updateWatches(ctx, reaper.Name, nil)
+		return controller.NewPermanentError(err)
+	}
+	status.MarkPolicyValid()
+
+	// Process each target. One that can't be resolved or listed doesn't
+	// stop the others.
+	var result reapResult
+	var resolved, unresolved, failed []string
+	var targetErr error
+	unresolvedReason := "TargetNotFound"
+	scopes := make(map[schema.GroupVersionResource][]targetScope)
+	status.Targets = make([]v1alpha1.TargetStatus, 0, len(reaper.Spec.Targets))
+	for i := range reaper.Spec.Targets {
+		target := &reaper.Spec.Targets[i]
+		tr, err := r.reconcileTarget(ctx, reaper, target, policies[i])
+		status.Targets = append(status.Targets, tr.status)
+		result.add(tr.result)
+		if tr.gvr != nil {
+			scopes[*tr.gvr] = append(scopes[*tr.gvr], tr.scope)
+		}
+		for _, namespace := range tr.failedNamespaces {
+			failed = append(failed, target.Name+"/"+namespace)
+		}
+		switch tr.status.Reason {
+		case "TargetNotFound":
+			unresolved = append(unresolved, fmt.Sprintf("%s (%s)", target.Name, tr.status.Message))
+			if previousReason(reaper.Status.Targets, target.Name) != "TargetNotFound" {
+				r.recorder.Eventf(reaper, corev1.EventTypeWarning, "TargetNotFound",
+					"Target %s: %s", target.Name, tr.status.Message)
+			}
+		case "ResolutionFailed":
+			unresolved = append(unresolved, fmt.Sprintf("%s (%s)", target.Name, tr.status.Message))
+			unresolvedReason = "ResolutionFailed"
+		case "NamespaceListFailed":
+			resolved = append(resolved, resolvedMessage(tr.status))
+			failed = append(failed, target.Name+" ("+tr.status.Message+")")
+		default:
+			resolved = append(resolved, resolvedMessage(tr.status))
+		}
+		if err != nil {
+			targetErr = err
+		}
+	}
+
+	// Watch the objects of every resolved target. Keep watching what could
+	// not be resolved this time for reasons other than not being served.
+	if unresolvedReason == "ResolutionFailed" {
+		for gvr, held := range r.watches.heldBy(reaper.Name) {
+			if _, ok := scopes[gvr]; !ok {
+				scopes[gvr] = held
+			}
+		}
+	}
+	r.updateWatches(ctx, reaper.Name, scopes)
+
+	if len(unresolved) > 0 {
+		status.MarkTargetNotResolved(unresolvedReason, "%d of %d targets could not be resolved: %s",
+			len(unresolved), len(reaper.Spec.Targets), strings.Join(unresolved, "; "))
+	} else {
+		status.MarkTargetResolved("%s", strings.Join(resolved, "; "))
 	}
 
-	// Create the GroupVersionResource
-	gvr := schema.GroupVersionResource{
-		Group:    gv.Group,
-		Version:  gv.Version,
-		Resource: getResourceName(reaper.Spec.TargetKind), // Convert Kind to resource name
+	if result.newlyScheduled > 0 {
+		r.recorder.Eventf(reaper, corev1.EventTypeNormal, "ReapScheduled",
+			"Scheduled the deletion of %d objects, %d pending in all, the next at %s",
+			result.newlyScheduled, result.pending, result.nextDeletion().UTC().Format(time.RFC3339))
 	}
 
-	totalReaped := 0
+	status.Matched = result.matched
+	status.Pending = result.pending
+	status.NextDeletionTime = result.nextDeletion()
+	status.ScheduledDeletions = result.scheduled
+	status.DryRunDeletions = result.dryRunDeletions
+	if len(failed) > 0 {
+		status.MarkDegraded("NamespaceProcessingFailed", "failed to process %s", strings.Join(failed, ", "))
+	} else {
+		status.MarkNotDegraded()
+	}
+	if len(failed) == 0 && len(unresolved) == 0 {
+		// Every object was seen, so the ones not observed this time are gone
+		// or no longer match
+		r.completions.forgetUnseenSince(reaper.Name, status.LastProcessedTime.Time)
+		r.dryRunReports.forgetUnseenSince(reaper.Name, status.LastProcessedTime.Time)
+		r.evaluationFailures.forgetUnseenSince(reaper.Name, status.LastProcessedTime.Time)
+		r.schedule.cancelUnscheduledSince(reaper.Name, status.LastProcessedTime.Time)
+	}
+
+	logger.Infow("🎯 TTL scheduling cycle completed",
+		zap.String("ttlreaper", reaper.Name),
+		zap.Int("targets", len(reaper.Spec.Targets)),
+		zap.Int32("matched", result.matched),
+		zap.Int32("pending", result.pending))
+
+	// Retry targets that failed, with backoff. Kinds not served yet are
+	// also picked up right away when their CRD is installed.
+	return targetErr
+}
+
+// previousReason returns the reason recorded for the named target by the
+// last reconcile, if any.
+func previousReason(targets []v1alpha1.TargetStatus, name string) string {
+	for _, ts := range targets {
+		if ts.Name == name {
+			return ts.Reason
+		}
+	}
+	return ""
+}
+
+// resolvedMessage describes what a resolved target maps to.
+func resolvedMessage(ts v1alpha1.TargetStatus) string {
+	if ts.NamespaceIgnored {
+		return ts.Resource + " is cluster-scoped; namespace settings are ignored"
+	}
+	return ts.Resource + " is " + strings.ToLower(string(ts.Scope))
+}
+
+// targetResult is what reconciling one target found.
+type targetResult struct {
+	// gvr is the resource the target resolved to, nil if it didn't
+	gvr *schema.GroupVersionResource
+	// scope is where the target may match objects of gvr
+	scope            targetScope
+	status           v1alpha1.TargetStatus
+	result           reapResult
+	failedNamespaces []string
+}
+
+// reconcileTarget resolves a target's kind, picks the namespaces to look in
+// and schedules deletion of its finished objects there.
+func (r *Reconciler) reconcileTarget(ctx context.Context, reaper *v1alpha1.TTLReaper, target *v1alpha1.TargetSpec, policy *reapPolicy) (targetResult, error) {
+	logger := logging.FromContext(ctx).With(zap.String("ttlreaper", reaper.Name), zap.String("target", target.Name))
+	tr := targetResult{status: v1alpha1.TargetStatus{Name: target.Name}}
+
+	// Resolve the target kind to the resource served by the API server
+	mapping, err := r.resolver.resolveFresh(target.APIVersion, target.Kind)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			// Nothing can be reaped until the kind is installed. The CRD informer
+			// re-enqueues every TTLReaper when that happens; retrying with
+			// backoff covers kinds served by other API servers.
+			logger.Errorw("Target kind is not served by the API server",
+				zap.String("kind", target.Kind),
+				zap.String("apiVersion", target.APIVersion),
+				zap.Error(err))
+			tr.status.Reason = "TargetNotFound"
+			tr.status.Message = fmt.Sprintf("%s %s is not served by the API server", target.APIVersion, target.Kind)
+			return tr, err
+		}
+		logger.Errorw("Failed to resolve target kind", zap.Error(err))
+		tr.status.Reason, tr.status.Message = "ResolutionFailed", err.Error()
+		return tr, err
+	}
+	gvr := mapping.Resource
+	tr.gvr = &gvr
+	tr.scope = targetScope{namespace: metav1.NamespaceAll, selector: policy.selector}
+	tr.status.Resource = gvr.String()
 
 	// Determine namespaces to process
 	namespaces := []string{}
-	if reaper.Spec.TargetNamespace != "" {
-		namespaces = append(namespaces, reaper.Spec.TargetNamespace)
-	} else {
-		// List all namespaces
-		nsList, err := r.kubeclientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to list namespaces: %w", err)
+	switch {
+	case mapping.Scope.Name() == meta.RESTScopeNameRoot:
+		// Cluster-scoped kinds are listed and deleted without a namespace
+		tr.status.Scope = v1alpha1.TargetScopeCluster
+		if target.TargetNamespace != "" || target.NamespaceSelector != nil || len(target.ExcludedNamespaces) > 0 {
+			logger.Warnw("Namespace settings are ignored for cluster-scoped kinds",
+				zap.String("kind", target.Kind))
+			tr.status.NamespaceIgnored = true
 		}
-		for _, ns := range nsList.Items {
-			namespaces = append(namespaces, ns.Name)
+		namespaces = append(namespaces, metav1.NamespaceNone)
+	default:
+		tr.status.Scope = v1alpha1.TargetScopeNamespaced
+		if target.TargetNamespace != "" {
+			// Namespaces picked by selector come and go, so only a
+			// targetNamespace narrows the watch
+			tr.scope.namespace = target.TargetNamespace
+		}
+		namespaces, err = r.targetNamespaces(&target.TargetSettings)
+		if err != nil {
+			tr.status.Reason, tr.status.Message = "NamespaceListFailed", err.Error()
+			return tr, err
 		}
 	}
+	tr.status.Namespaces = int32(len(namespaces))
 
 	// Process each namespace
 	for _, namespace := range namespaces {
-		scheduled, err := r.processNamespace(ctx, namespace, gvr, reaper.Spec.LabelSelector)
+		nsResult, err := r.processNamespace(ctx, reaper, target, policy, namespace, gvr)
 		if err != nil {
 			logger.Errorw("Error processing namespace",
 				zap.String("namespace", namespace),
 				zap.Error(err))
+			tr.failedNamespaces = append(tr.failedNamespaces, namespace)
 			// Continue with other namespaces even if one fails
 			continue
 		}
-		totalReaped += scheduled
+		tr.result.add(nsResult)
 	}
 
-	logger.Infow("🎯 TTL scheduling cycle completed",
-		zap.String("ttlreaper", reaper.Name),
-		zap.String("targetKind", reaper.Spec.TargetKind),
-		zap.String("targetAPIVersion", reaper.Spec.TargetAPIVersion),
-		zap.String("targetNamespace", reaper.Spec.TargetNamespace),
-		zap.Int("namespacesProcessed", len(namespaces)),
-		zap.Int("totalScheduled", totalReaped))
+	tr.status.Matched = tr.result.matched
+	tr.status.Pending = tr.result.pending
+	tr.status.NextDeletionTime = tr.result.nextDeletion()
+	if len(tr.failedNamespaces) > 0 {
+		tr.status.Reason = "NamespaceProcessingFailed"
+		tr.status.Message = fmt.Sprintf("failed to process %d of %d namespaces: %s",
+			len(tr.failedNamespaces), len(namespaces), strings.Join(tr.failedNamespaces, ", "))
+	}
+	return tr, nil
+}
 
-	return nil
+// updateStatus writes status back to the TTLReaper if it changed.
+func (r *Reconciler) updateStatus(ctx context.Context, reaper *v1alpha1.TTLReaper, status *v1alpha1.TTLReaperStatus) error {
+	// A sweep that changed nothing else isn't worth a write
+	unchanged := status.DeepCopy()
+	unchanged.LastProcessedTime = reaper.Status.LastProcessedTime
+	if equality.Semantic.DeepEqual(&reaper.Status, unchanged) {
+		return nil
+	}
+
+	existing := reaper.DeepCopy()
+	existing.Status = *status
+	_, err := r.clientset.ClusteropsV1alpha1().TTLReapers().UpdateStatus(ctx, existing, metav1.UpdateOptions{})
+	return err
 }
 
-func (r *Reconciler) processNamespace(ctx context.Context, namespace string, gvr schema.GroupVersionResource, labelSelector *metav1.LabelSelector) (int, error) {
-	logger := logging.FromContext(ctx).With(zap.String("namespace", namespace))
+// deletionCounts counts deletions by cause.
+type deletionCounts struct {
+	// reaped objects outlived their TTL
+	reaped int32
+	// pruned objects exceeded their group's retention limits
+	pruned int32
+	// maxAge objects outlived the reaper's maxAge
+	maxAge int32
+	// failed attempts to delete an object
+	failed int32
+}
 
-	// Build list options
-	listOptions := metav1.ListOptions{}
-	if labelSelector != nil {
-		selector, err := metav1.LabelSelectorAsSelector(labelSelector)
-		if err != nil {
-			return 0, fmt.Errorf("invalid label selector: %w", err)
-		}
-		listOptions.LabelSelector = selector.String()
+// add adds n to the counts of status.
+func (n deletionCounts) add(status *v1alpha1.TTLReaperStatus) {
+	status.TotalReaped += n.reaped
+	status.TotalPruned += n.pruned
+	status.TotalMaxAgeReaped += n.maxAge
+	status.TotalFailedDeletions += n.failed
+}
+
+// recordDeletions adds to the counts not yet written to the reaper's status,
+// and has them written after statusFlushDelay unless a reconcile does first.
+func (r *Reconciler) recordDeletions(reaperName string, n deletionCounts) {
+	r.deletionsMutex.Lock()
+	counts := r.deletions[reaperName]
+	counts.reaped += n.reaped
+	counts.pruned += n.pruned
+	counts.maxAge += n.maxAge
+	counts.failed += n.failed
+	r.deletions[reaperName] = counts
+	r.deletionsMutex.Unlock()
+
+	r.statusQueue.AddAfter(reaperName, statusFlushDelay)
+}
+
+// takeDeletions returns and clears the counts not yet written to the
+// reaper's status.
+func (r *Reconciler) takeDeletions(reaperName string) deletionCounts {
+	r.deletionsMutex.Lock()
+	defer r.deletionsMutex.Unlock()
+	counts := r.deletions[reaperName]
+	delete(r.deletions, reaperName)
+	return counts
+}
+
+// resourceClient returns a client for gvr in the given namespace. An empty
+// namespace yields a cluster-level client, which is what cluster-scoped kinds
+// (and listing across all namespaces) need.
+func (r *Reconciler) resourceClient(gvr schema.GroupVersionResource, namespace string) dynamic.ResourceInterface {
+	if namespace == metav1.NamespaceNone {
+		return r.dynamicClient.Resource(gvr)
 	}
+	return r.dynamicClient.Resource(gvr).Namespace(namespace)
+}
+
+// reapResult tallies what a reconcile did with the objects it matched.
+type reapResult struct {
+	matched int32
+	pending int32
+
+	// newlyScheduled counts the pending deletions that are new, or whose
+	// time or reason changed
+	newlyScheduled int32
+
+	// scheduled holds the soonest pending deletions, sorted by expiration
+	// and bounded by v1alpha1.MaxScheduledDeletions
+	scheduled []v1alpha1.ScheduledDeletion
+
+	// dryRunDeletions holds, the same way, the deletions a dry-run reaper
+	// would have done already
+	dryRunDeletions []v1alpha1.ScheduledDeletion
+}
+
+func (rr *reapResult) add(other reapResult) {
+	rr.matched += other.matched
+	rr.pending += other.pending
+	rr.newlyScheduled += other.newlyScheduled
+	for _, deletion := range other.scheduled {
+		rr.schedule(deletion)
+	}
+	for _, deletion := range other.dryRunDeletions {
+		rr.dryRun(deletion)
+	}
+}
+
+// schedule records a pending deletion, keeping only the soonest ones.
+func (rr *reapResult) schedule(deletion v1alpha1.ScheduledDeletion) {
+	rr.scheduled = insertDeletion(rr.scheduled, deletion)
+}
+
+// dryRun records a deletion a dry-run reaper would have done, keeping only
+// the earliest ones.
+func (rr *reapResult) dryRun(deletion v1alpha1.ScheduledDeletion) {
+	rr.dryRunDeletions = insertDeletion(rr.dryRunDeletions, deletion)
+}
+
+// insertDeletion inserts deletion into a list sorted by expiration and
+// bounded by v1alpha1.MaxScheduledDeletions.
+func insertDeletion(deletions []v1alpha1.ScheduledDeletion, deletion v1alpha1.ScheduledDeletion) []v1alpha1.ScheduledDeletion {
+	i := sort.Search(len(deletions), func(i int) bool {
+		return deletion.ExpirationTime.Before(&deletions[i].ExpirationTime)
+	})
+	if i >= v1alpha1.MaxScheduledDeletions {
+		return deletions
+	}
+	deletions = append(deletions, v1alpha1.ScheduledDeletion{})
+	copy(deletions[i+1:], deletions[i:])
+	deletions[i] = deletion
+	if len(deletions) > v1alpha1.MaxScheduledDeletions {
+		deletions = deletions[:v1alpha1.MaxScheduledDeletions]
+	}
+	return deletions
+}
+
+// nextDeletion returns the earliest pending deletion time, or nil.
+func (rr *reapResult) nextDeletion() *metav1.Time {
+	if len(rr.scheduled) == 0 {
+		return nil
+	}
+	return rr.scheduled[0].ExpirationTime.DeepCopy()
+}
+
+// processNamespace schedules deletion of the target's objects in one
+// namespace, reading them from the informer cache.
+func (r *Reconciler) processNamespace(ctx context.Context, reaper *v1alpha1.TTLReaper, target *v1alpha1.TargetSpec, policy *reapPolicy, namespace string, gvr schema.GroupVersionResource) (reapResult, error) {
+	logger := logging.FromContext(ctx).With(zap.String("namespace", namespace))
+	var result reapResult
 
 	// List resources of the target kind in the namespace
-	resourceList, err := r.dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, listOptions)
+	items, err := r.listTargets(ctx, gvr, namespace, policy.selector)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Resource type doesn't exist in this cluster, skip
 			logger.Debugw("Resource type not found in cluster", zap.String("gvr", gvr.String()))
-			return 0, nil
+			return result, nil
 		}
-		return 0, fmt.Errorf("failed to list resources %s in namespace %s: %w", gvr.String(), namespace, err)
+		return result, fmt.Errorf("failed to list resources %s in namespace %s: %w", gvr.String(), namespace, err)
 	}
 
-	scheduled := 0
-	for _, item := range resourceList.Items {
-		resourceName := item.GetName()
-		resourceKey := fmt.Sprintf("%s/%s/%s", namespace, item.GetKind(), resourceName)
+	result.matched = int32(len(items))
 
-		// Check if resource has TTL field
-		ttlSeconds, hasTTL, err := unstructured.NestedInt64(item.Object, "spec", "ttlSecondsAfterFinished")
-		if err != nil || !hasTTL {
-			continue
+	// Work out which resources finished, how and when
+	objects := make([]*targetObject, 0, len(items))
+	for _, item := range items {
+		if obj := r.evaluate(ctx, reaper, policy, gvr, item); obj != nil {
+			objects = append(objects, obj)
 		}
+	}
 
-		// Check if resource is finished
-		if !r.isResourceFinished(&item) {
-			continue
-		}
+	// Delete the resources beyond the retention limits before looking at
+	// TTLs, so they aren't scheduled as well
+	pruned := r.prune(ctx, reaper, target, policy, objects, gvr, &result)
 
-		// Schedule deletion at exact TTL expiration time (like Jobs)
-		r.scheduleResourceDeletion(ctx, resourceKey, &item, gvr, ttlSeconds)
-		scheduled++
+	for _, obj := range objects {
+		if !pruned[obj] {
+			r.scheduleObject(ctx, reaper, target, policy, obj, gvr, &result)
+		}
 	}
 
-	return scheduled, nil
+	return result, nil
 }
 
-func (r *Reconciler) scheduleResourceDeletion(ctx context.Context, resourceKey string, resource *unstructured.Unstructured, gvr schema.GroupVersionResource, ttlSeconds int64) {
-	logger := logging.FromContext(ctx)
+// evaluate works out whether a target object finished, how and when. It
+// returns nil for objects annotated to be kept, which are left alone
+// entirely.
+func (r *Reconciler) evaluate(ctx context.Context, reaper *v1alpha1.TTLReaper, policy *reapPolicy, gvr schema.GroupVersionResource, item *unstructured.Unstructured) *targetObject {
+	logger := logging.FromContext(ctx).With(zap.String("resource", item.GetName()))
 
-	// Get completion time
-	var finishTime time.Time
-	completionTimeStr, found, err := unstructured.NestedString(resource.Object, "status", "completionTime")
-	if found && err == nil {
-		if parsedTime, parseErr := time.Parse(time.RFC3339, completionTimeStr); parseErr == nil {
-			finishTime = parsedTime
+	// Objects their owners protected are left alone entirely
+	if kept(item) {
+		logger.Debugw("Skipping resource annotated to be kept")
+		r.schedule.cancel(reaper.Name, item.GetUID())
+		return nil
+	}
+
+	obj := &targetObject{item: item}
+
+	// Check if resource is finished, and how it ended
+	outcome, finished, err := policy.outcome(item)
+	if err != nil {
+		logger.Warnw("Failed to classify resource", zap.Error(err))
+		r.reportEvaluationFailure(reaper, gvr, item, err)
+		return obj
+	}
+	if !finished {
+		return obj
+	}
+
+	// Find when the resource finished, which its TTL counts from
+	finishTime, found, err := policy.finishTime(item)
+	if err != nil {
+		logger.Warnw("Ignoring resource with invalid finish time", zap.Error(err))
+		r.reportEvaluationFailure(reaper, gvr, item, err)
+		return obj
+	}
+	obj.finished, obj.outcome = true, outcome
+	obj.finishTime, obj.hasFinishTime = finishTime, true
+	if !found {
+		switch policy.missingFinishTime {
+		case v1alpha1.MissingFinishTimeSkip:
+			logger.Debugw("Not scheduling finished resource without a finish time")
+			obj.finishTime = item.GetCreationTimestamp().Time
+			obj.hasFinishTime = false
+		case v1alpha1.MissingFinishTimeCreationTime:
+			obj.finishTime = item.GetCreationTimestamp().Time
+		default:
+			obj.finishTime = r.finishObserved(ctx, reaper, gvr, obj)
 		}
 	}
+	return obj
+}
 
-	// Fallback to creation time if no completion time
-	if finishTime.IsZero() {
-		finishTime = resource.GetCreationTimestamp().Time
+// reportEvaluationFailure reports with a Warning Event on the reaper that
+// evaluating its spec against the object failed, unless that was reported
+// since the object started failing. Such objects are never reaped, so the
+// Event tells users their expressions need fixing.
+func (r *Reconciler) reportEvaluationFailure(reaper *v1alpha1.TTLReaper, gvr schema.GroupVersionResource, item *unstructured.Unstructured, err error) {
+	now := r.schedule.now()
+	if first := r.evaluationFailures.observe(reaper.Name, item.GetUID(), now); first.Equal(now) {
+		r.recorder.Eventf(reaper, corev1.EventTypeWarning, "EvaluationFailed",
+			"Failed to evaluate %s %s, not reaping it: %v", gvr.Resource, objectName(item), err)
 	}
+}
 
-	// Calculate exact expiration time
-	ttlDuration := time.Duration(ttlSeconds) * time.Second
-	expirationTime := finishTime.Add(ttlDuration)
+// finishObserved returns when the object was first observed finished. That
+// time is only remembered in memory, unless annotate-finish-observed has it
+// recorded on the object too, so that its TTL counts from the same time
+// after a restart or a change of leader; obj then holds the object as
+// patched. Dry-run reapers always leave the object alone.
+func (r *Reconciler) finishObserved(ctx context.Context, reaper *v1alpha1.TTLReaper, gvr schema.GroupVersionResource, obj *targetObject) time.Time {
+	item := obj.item
+	logger := logging.FromContext(ctx).With(zap.String("resource", item.GetName()))
 
-	// Calculate delay until expiration
-	delay := time.Until(expirationTime)
+	observed, found, err := finishObservedAt(item)
+	if err != nil {
+		logger.Warnw("Ignoring invalid annotation", zap.Error(err))
+	} else if found {
+		return observed
+	}
 
-	// Cancel existing timer if any
-	r.timersMutex.Lock()
-	if existingTimer, exists := r.timers[resourceKey]; exists {
-		existingTimer.Stop()
-		delete(r.timers, resourceKey)
+	// Annotations only hold whole seconds
+	observed = r.completions.observe(reaper.Name, item.GetUID(), r.schedule.now()).UTC().Truncate(time.Second)
+	if reaper.Spec.DryRun || !r.annotateFinishObserved.Load() {
+		return observed
 	}
 
-	// If already expired, delete immediately
-	if delay <= 0 {
-		r.timersMutex.Unlock()
-		logger.Infow("🗑️  REAPING EXPIRED RESOURCE",
-			zap.String("resource", resource.GetName()),
-			zap.String("kind", resource.GetKind()),
-			zap.String("namespace", resource.GetNamespace()),
-			zap.Int64("ttlSeconds", ttlSeconds))
+	// Only annotate the object as it was evaluated; when it changed, its
+	// next evaluation annotates it
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": item.GetResourceVersion(),
+			"annotations": map[string]string{
+				v1alpha1.FinishObservedAtAnnotation: observed.Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return observed
+	}
+	patched, err := r.resourceClient(gvr, item.GetNamespace()).Patch(ctx, item.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	switch {
+	case errors.IsConflict(err), errors.IsNotFound(err):
+		logger.Debugw("Resource changed before it could be annotated", zap.Error(err))
+	case err != nil:
+		logger.Warnw("Failed to record when resource was first observed finished", zap.Error(err))
+	default:
+		obj.item = patched
+	}
+	return observed
+}
 
-		err := r.dynamicClient.Resource(gvr).Namespace(resource.GetNamespace()).Delete(ctx, resource.GetName(), metav1.DeleteOptions{})
+// prune deletes the objects, all from one namespace, that exceed the
+// retention limits of the target and returns them.
+func (r *Reconciler) prune(ctx context.Context, reaper *v1alpha1.TTLReaper, target *v1alpha1.TargetSpec, policy *reapPolicy, objects []*targetObject, gvr schema.GroupVersionResource, result *reapResult) map[*targetObject]bool {
+	pruned := make(map[*targetObject]bool)
+	if policy.retention == nil {
+		return pruned
+	}
+	for _, obj := range policy.retention.excess(objects) {
+		pruned[obj] = true
+		if reportedAt := r.pruneResource(ctx, reaper, obj, gvr); reaper.Spec.DryRun {
+			result.dryRun(v1alpha1.ScheduledDeletion{
+				Target:         target.Name,
+				Namespace:      obj.item.GetNamespace(),
+				Name:           obj.item.GetName(),
+				ExpirationTime: metav1.NewTime(reportedAt),
+				Reason:         v1alpha1.DeletionReasonRetentionLimitExceeded,
+				Outcome:        obj.outcome,
+			})
+		}
+	}
+	return pruned
+}
+
+// scheduleObject works out when a target object expires and schedules its
+// deletion, or cancels any deletion pending for it when it doesn't expire.
+func (r *Reconciler) scheduleObject(ctx context.Context, reaper *v1alpha1.TTLReaper, target *v1alpha1.TargetSpec, policy *reapPolicy, obj *targetObject, gvr schema.GroupVersionResource, result *reapResult) {
+	item := obj.item
+	resourceName := item.GetName()
+	logger := logging.FromContext(ctx).With(zap.String("resource", resourceName))
+
+	// Resources expire at the deadline their owner set, or else, once
+	// finished, when their TTL passes
+	var expirationTime time.Time
+	var reason v1alpha1.DeletionReason
+	var ttlSource v1alpha1.TTLSource
+	deadline, hasDeadline, err := expiresAt(item)
+	if err != nil {
+		logger.Warnw("Ignoring resource with invalid annotation", zap.Error(err))
+		r.schedule.cancel(reaper.Name, item.GetUID())
+		return
+	}
+	if hasDeadline {
+		expirationTime, reason = deadline, v1alpha1.DeletionReasonDeadlineReached
+	} else if obj.finished && obj.hasFinishTime {
+		ttl, source, hasTTL, err := policy.ttl(item, obj.outcome)
 		if err != nil {
-			logger.Errorw("❌ Failed to delete expired resource", zap.Error(err))
-		} else {
-			logger.Infow("✅ Successfully deleted expired resource",
-				zap.String("resource", resource.GetName()))
+			logger.Warnw("Ignoring invalid TTL of resource", zap.Error(err))
+		} else if hasTTL {
+			expirationTime = obj.finishTime.Add(ttl)
+			reason, ttlSource = v1alpha1.DeletionReasonTTLExpired, source
+		}
+	}
+
+	// Any resource expires once it outlives maxAge, if that comes first
+	if policy.maxAge != nil {
+		deadline := item.GetCreationTimestamp().Add(*policy.maxAge)
+		if expirationTime.IsZero() || deadline.Before(expirationTime) {
+			expirationTime = deadline
+			reason, ttlSource = v1alpha1.DeletionReasonMaxAgeExceeded, ""
 		}
+	}
+
+	// Nothing to schedule, and any deletion left from an earlier reconcile
+	// (e.g. before an annotation changed) is stale
+	if expirationTime.IsZero() {
+		r.schedule.cancel(reaper.Name, item.GetUID())
 		return
 	}
 
-	// Schedule timer for exact expiration time
-	timer := time.AfterFunc(delay, func() {
-		logger.Infow("🗑️  REAPING EXPIRED RESOURCE (Timer)",
+	// Schedule deletion at exact expiration time (like Jobs)
+	deletion := v1alpha1.ScheduledDeletion{
+		Target:         target.Name,
+		Namespace:      item.GetNamespace(),
+		Name:           resourceName,
+		ExpirationTime: metav1.NewTime(expirationTime),
+		Reason:         reason,
+		TTLSource:      ttlSource,
+		Outcome:        obj.outcome,
+	}
+	if pending, changed := r.scheduleResourceDeletion(ctx, reaper, item, gvr, expirationTime, reason, obj.outcome); pending {
+		result.pending++
+		result.schedule(deletion)
+		if changed {
+			result.newlyScheduled++
+		}
+	} else if reaper.Spec.DryRun {
+		// Expired, but still around since this is a dry run
+		result.dryRun(deletion)
+	}
+}
+
+// pruneResource deletes a finished resource that exceeded its group's
+// retention limits, along with any deletion pending for it. For dry-run
+// reapers it returns when the deletion was first reported.
+func (r *Reconciler) pruneResource(ctx context.Context, reaper *v1alpha1.TTLReaper, obj *targetObject, gvr schema.GroupVersionResource) time.Time {
+	logger := logging.FromContext(ctx)
+	resource := obj.item
+	now := r.schedule.now()
+	if r.schedule.keep(reaper.Name, resource.GetUID(), resource.GetResourceVersion(), now) {
+		// Already under way, or held back after failing
+		return now
+	}
+	r.schedule.cancel(reaper.Name, resource.GetUID())
+
+	logger.Infow("✂️  PRUNING RESOURCE BEYOND RETENTION LIMIT",
+		zap.String("resource", resource.GetName()),
+		zap.String("kind", resource.GetKind()),
+		zap.String("namespace", resource.GetNamespace()),
+		zap.String("outcome", string(obj.outcome)))
+
+	return r.reapResource(ctx, reaper, newScheduledDeletion(reaper.Name, resource, gvr,
+		v1alpha1.DeletionReasonRetentionLimitExceeded, obj.outcome, now, now))
+}
+
+// reapResource runs an expired deletion, through the delete workers.
+// Dry-run reapers only log and record an Event saying they would have, once
+// per object, and get back when they first did.
+func (r *Reconciler) reapResource(ctx context.Context, reaper *v1alpha1.TTLReaper, d *scheduledDeletion) time.Time {
+	logger := logging.FromContext(ctx).With(
+		zap.String("resource", d.ref.name),
+		zap.String("kind", d.kind),
+		zap.String("namespace", d.ref.namespace),
+		zap.String("reason", string(d.reason)))
+
+	if reaper.Spec.DryRun {
+		now := time.Now()
+		reportedAt := r.dryRunReports.observe(reaper.Name, d.uid, now)
+		if reportedAt.Equal(now) {
+			logger.Infow("🧪 DRY RUN: would have deleted resource")
+			r.recorder.Eventf(reaper, corev1.EventTypeNormal, "WouldReap",
+				"Dry run: would have deleted %s %s (%s)", d.ref.gvr.Resource, objectName(d.object()), d.reason)
+		}
+		return reportedAt
+	}
+
+	r.schedule.schedule(d)
+	return time.Time{}
+}
+
+// objectName returns namespace/name, or just the name of cluster-scoped
+// objects.
+func objectName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// recordDeletion counts a deletion, both towards the reaper's status and in
+// the metrics, along with how late it came.
+func (r *Reconciler) recordDeletion(ctx context.Context, d *scheduledDeletion) {
+	attrs := []attribute.KeyValue{
+		reaperAttr.String(d.reaperName),
+		resourceAttr.String(d.ref.gvr.String()),
+		namespaceAttr.String(d.ref.namespace),
+	}
+	reapedObjects.Add(ctx, 1, metric.WithAttributes(append(attrs,
+		reasonAttr.String(string(d.reason)),
+		outcomeAttr.String(string(d.outcome)))...))
+	if lag := r.schedule.now().Sub(d.expiration); lag >= 0 {
+		reapingLag.Record(ctx, lag.Seconds(), metric.WithAttributes(attrs...))
+	}
+
+	var n deletionCounts
+	switch d.reason {
+	case v1alpha1.DeletionReasonMaxAgeExceeded:
+		n.maxAge = 1
+		maxAgeDeletions.Add(ctx, 1, metric.WithAttributes(attrs...))
+	case v1alpha1.DeletionReasonRetentionLimitExceeded:
+		n.pruned = 1
+	default:
+		n.reaped = 1
+	}
+	r.recordDeletions(d.reaperName, n)
+}
+
+// scheduleResourceDeletion deletes the resource right away if expirationTime
+// has passed, or schedules its deletion otherwise. It returns whether a
+// deletion is now pending for the resource, and whether it is new or its
+// time or reason changed.
+func (r *Reconciler) scheduleResourceDeletion(ctx context.Context, reaper *v1alpha1.TTLReaper, resource *unstructured.Unstructured, gvr schema.GroupVersionResource, expirationTime time.Time, reason v1alpha1.DeletionReason, outcome v1alpha1.Outcome) (pending, changed bool) {
+	logger := logging.FromContext(ctx)
+
+	// Calculate delay until expiration
+	now := r.schedule.now()
+	delay := expirationTime.Sub(now)
+	d := newScheduledDeletion(reaper.Name, resource, gvr, reason, outcome, expirationTime, now)
+
+	// If already expired, delete immediately, unless that is under way:
+	// failed deletions are retried with backoff, or held back
+	if delay <= 0 {
+		if r.schedule.keep(reaper.Name, resource.GetUID(), resource.GetResourceVersion(), now) {
+			return false, false
+		}
+		r.schedule.cancel(reaper.Name, resource.GetUID())
+		logger.Infow("🗑️  REAPING EXPIRED RESOURCE",
 			zap.String("resource", resource.GetName()),
 			zap.String("kind", resource.GetKind()),
 			zap.String("namespace", resource.GetNamespace()),
-			zap.Int64("ttlSeconds", ttlSeconds))
+			zap.String("reason", string(reason)))
 
-		err := r.dynamicClient.Resource(gvr).Namespace(resource.GetNamespace()).Delete(context.Background(), resource.GetName(), metav1.DeleteOptions{})
-		if err != nil {
-			logger.Errorw("❌ Failed to delete expired resource", zap.Error(err))
-		} else {
-			logger.Infow("✅ Successfully deleted expired resource",
-				zap.String("resource", resource.GetName()))
-		}
-
-		// Clean up timer
-		r.timersMutex.Lock()
-		delete(r.timers, resourceKey)
-		r.timersMutex.Unlock()
-	})
+		r.reapResource(ctx, reaper, d)
+		return false, false
+	}
 
-	r.timers[resourceKey] = timer
-	r.timersMutex.Unlock()
+	// Schedule deletion at exact expiration time (like Jobs). Only a
+	// reference to the resource as it is now is kept, and it is only
+	// deleted if it didn't change.
+	if !r.schedule.schedule(d) {
+		return true, false
+	}
 
-	logger.Infow("⏰ Scheduled TTL deletion",
+	logger.Infow("⏰ Scheduled deletion",
 		zap.String("resource", resource.GetName()),
+		zap.String("reason", string(reason)),
+		zap.Bool("dryRun", reaper.Spec.DryRun),
 		zap.Duration("delay", delay),
 		zap.Time("expirationTime", expirationTime))
+
+	if r.targetEvents.Load() && !reaper.Spec.DryRun {
+		r.recorder.Eventf(resource, corev1.EventTypeNormal, "WillBeReaped",
+			"Will be deleted by TTLReaper %s at %s (%s)", reaper.Name, expirationTime.UTC().Format(time.RFC3339), reason)
+	}
+	return true, true
 }
 
-func (r *Reconciler) isResourceFinished(resource *unstructured.Unstructured) bool {
+// classifyOutcome applies the built-in heuristics to tell whether a resource
+// finished and how it ended. Finished resources that don't say how they
+// ended are classified as v1alpha1.OutcomeUnknown.
+func classifyOutcome(resource *unstructured.Unstructured) (v1alpha1.Outcome, bool) {
 	// Check common completion status patterns
 
-	// Pattern 1: status.phase == "Succeeded" or "Failed" (common in Jobs, etc.)
+	// Pattern 1: status.phase (Pods, Argo Workflows, etc.)
 	if phase, found, err := unstructured.NestedString(resource.Object, "status", "phase"); found && err == nil {
-		return phase == "Succeeded" || phase == "Failed" || phase == "Completed"
+		switch phase {
+		case "Succeeded", "Completed":
+			return v1alpha1.OutcomeSucceeded, true
+		case "Failed", "Error":
+			return v1alpha1.OutcomeFailed, true
+		default:
+			return "", false
+		}
 	}
 
-	// Pattern 2: status.conditions with type="Succeeded" and status="True"
+	// Pattern 2: status.conditions
+	//   - type="Succeeded" (Knative/Tekton style): "True" means succeeded and
+	//     "False" means failed, or cancelled if the reason says so
+	//   - type="Completed" or "Complete" (Jobs) with status="True"
+	//   - type="Failed" (Jobs) with status="True"
 	conditions, found, err := unstructured.NestedSlice(resource.Object, "status", "conditions")
 	if found && err == nil {
 		for _, conditionInterface := range conditions {
 			if condition, ok := conditionInterface.(map[string]interface{}); ok {
 				condType, typeFound := condition["type"].(string)
 				condStatus, statusFound := condition["status"].(string)
-				if typeFound && statusFound {
-					if (condType == "Succeeded" || condType == "Completed") && condStatus == "True" {
-						return true
+				if !typeFound || !statusFound {
+					continue
+				}
+				switch {
+				case condType == "Succeeded" && condStatus == "True":
+					return v1alpha1.OutcomeSucceeded, true
+				case condType == "Succeeded" && condStatus == "False":
+					reason, _ := condition["reason"].(string)
+					if strings.Contains(strings.ToLower(reason), "cancel") {
+						return v1alpha1.OutcomeCancelled, true
 					}
+					return v1alpha1.OutcomeFailed, true
+				case (condType == "Completed" || condType == "Complete") && condStatus == "True":
+					return v1alpha1.OutcomeSucceeded, true
+				case condType == "Failed" && condStatus == "True":
+					return v1alpha1.OutcomeFailed, true
 				}
 			}
 		}
@@ -298,35 +1004,8 @@ func (r *Reconciler) isResourceFinished(resource *unstructured.Unstructured) boo
 	// Pattern 3: status.completionTime exists (indicates completion)
 	_, found, err = unstructured.NestedString(resource.Object, "status", "completionTime")
 	if found && err == nil {
-		return true
-	}
-
-	return false
-}
-
-// getResourceName converts a Kind to a resource name (pluralized, lowercase)
-func getResourceName(kind string) string {
-	// Simple pluralization - in a real implementation, you might want to use
-	// a more sophisticated approach or discovery client
-	lower := strings.ToLower(kind)
-	if strings.HasSuffix(lower, "y") {
-		return strings.TrimSuffix(lower, "y") + "ies"
-	}
-	if strings.HasSuffix(lower, "s") || strings.HasSuffix(lower, "x") || strings.HasSuffix(lower, "z") {
-		return lower + "es"
+		return v1alpha1.OutcomeUnknown, true
 	}
-	return lower + "s"
-}
-
-// Promote implements reconciler.LeaderAware
-func (r *Reconciler) Promote(bkt reconciler.Bucket, enq func(reconciler.Bucket, types.NamespacedName)) error {
-	// This is called when we become the leader.
-	// For this simple controller, we don't need to do anything special.
-	return nil
-}
 
-// Demote implements reconciler.LeaderAware
-func (r *Reconciler) Demote(bkt reconciler.Bucket) {
-	// This is called when we are no longer the leader.
-	// For this simple controller, we don't need to do anything special.
+	return "", false
 }
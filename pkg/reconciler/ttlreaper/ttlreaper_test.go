@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/infernus01/knative-demo/pkg/apis/clusterops/v1alpha1"
+)
+
+func TestClassifyOutcome(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       map[string]interface{}
+		wantOutcome  v1alpha1.Outcome
+		wantFinished bool
+	}{{
+		name: "no status",
+	}, {
+		name:         "succeeded phase",
+		status:       map[string]interface{}{"phase": "Succeeded"},
+		wantOutcome:  v1alpha1.OutcomeSucceeded,
+		wantFinished: true,
+	}, {
+		name:         "error phase",
+		status:       map[string]interface{}{"phase": "Error"},
+		wantOutcome:  v1alpha1.OutcomeFailed,
+		wantFinished: true,
+	}, {
+		name:   "running phase",
+		status: map[string]interface{}{"phase": "Running"},
+	}, {
+		name:         "Succeeded condition true",
+		status:       conditions("Succeeded", "True", ""),
+		wantOutcome:  v1alpha1.OutcomeSucceeded,
+		wantFinished: true,
+	}, {
+		name:         "Succeeded condition false",
+		status:       conditions("Succeeded", "False", "Failed"),
+		wantOutcome:  v1alpha1.OutcomeFailed,
+		wantFinished: true,
+	}, {
+		name:         "Succeeded condition false because cancelled",
+		status:       conditions("Succeeded", "False", "PipelineRunCancelled"),
+		wantOutcome:  v1alpha1.OutcomeCancelled,
+		wantFinished: true,
+	}, {
+		name:   "Succeeded condition unknown",
+		status: conditions("Succeeded", "Unknown", "Running"),
+	}, {
+		name:         "Complete condition",
+		status:       conditions("Complete", "True", ""),
+		wantOutcome:  v1alpha1.OutcomeSucceeded,
+		wantFinished: true,
+	}, {
+		name:         "Failed condition",
+		status:       conditions("Failed", "True", "BackoffLimitExceeded"),
+		wantOutcome:  v1alpha1.OutcomeFailed,
+		wantFinished: true,
+	}, {
+		name:         "completionTime only",
+		status:       map[string]interface{}{"completionTime": "2024-01-01T00:00:00Z"},
+		wantOutcome:  v1alpha1.OutcomeUnknown,
+		wantFinished: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			if test.status != nil {
+				obj.Object["status"] = test.status
+			}
+			outcome, finished := classifyOutcome(obj)
+			if outcome != test.wantOutcome || finished != test.wantFinished {
+				t.Errorf("classifyOutcome() = (%q, %v), want (%q, %v)",
+					outcome, finished, test.wantOutcome, test.wantFinished)
+			}
+		})
+	}
+}
+
+// conditions returns a status holding a single condition.
+func conditions(condType, status, reason string) map[string]interface{} {
+	return map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": condType, "status": status, "reason": reason},
+		},
+	}
+}
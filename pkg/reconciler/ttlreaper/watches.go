@@ -0,0 +1,383 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+// targetWatches manages the dynamic informers of the resources TTLReapers
+// target. Informers are reference counted by the reapers needing them: they
+// are started when the first reaper needs their resource and stopped,
+// dropping their cache, when the last one no longer does.
+//
+// A resource is only watched where its reapers' targets may match objects:
+// in the namespaces they are limited to, unless one of them isn't, and
+// filtered by the label requirements all of their selectors share. The
+// watch is restarted when that changes.
+type targetWatches struct {
+	mu sync.RWMutex
+	// watches by resource
+	watches map[schema.GroupVersionResource]*targetWatch
+	// held has the scopes of the targets of each reaper, by reaper name and
+	// resource
+	held map[string]map[schema.GroupVersionResource][]targetScope
+
+	// newInformer creates the informer of a resource in a namespace, or in
+	// all of them for metav1.NamespaceAll, event handlers included
+	newInformer func(gvr schema.GroupVersionResource, namespace string, selector labels.Selector) cache.SharedIndexInformer
+}
+
+// targetScope is where a target may match objects of its resource: in a
+// namespace, or in all of them for metav1.NamespaceAll, among the objects
+// its label selector matches.
+type targetScope struct {
+	namespace string
+	selector  labels.Selector
+}
+
+// watchScope is what the watch of a resource covers.
+type watchScope struct {
+	// namespaces watched, nil for all of them
+	namespaces sets.Set[string]
+	// selector the watch filters objects with
+	selector labels.Selector
+}
+
+func (s watchScope) equal(other watchScope) bool {
+	return s.namespaces.Equal(other.namespaces) && s.selector.String() == other.selector.String()
+}
+
+// covers reports whether every object the selector matches passes the
+// watch's filter, i.e. whether the selector requires at least what the
+// watch does.
+func (s watchScope) covers(selector labels.Selector) bool {
+	required, _ := s.selector.Requirements()
+	requested, _ := selector.Requirements()
+	for _, r := range required {
+		if !containsRequirement(requested, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsRequirement(requirements labels.Requirements, r labels.Requirement) bool {
+	for _, other := range requirements {
+		if other.String() == r.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// combineScopes returns the narrowest watch scope covering every target
+// scope. Label selectors can't be unioned, so the watch filters on the
+// requirements they all share.
+func combineScopes(scopes []targetScope) watchScope {
+	namespaces := sets.New[string]()
+	allNamespaces := false
+	var common labels.Requirements
+	for i, scope := range scopes {
+		if scope.namespace == metav1.NamespaceAll {
+			allNamespaces = true
+		} else {
+			namespaces.Insert(scope.namespace)
+		}
+
+		requirements, _ := scope.selector.Requirements()
+		if i == 0 {
+			common = requirements
+			continue
+		}
+		shared := labels.Requirements{}
+		for _, r := range common {
+			if containsRequirement(requirements, r) {
+				shared = append(shared, r)
+			}
+		}
+		common = shared
+	}
+
+	if allNamespaces {
+		namespaces = nil
+	}
+	return watchScope{
+		namespaces: namespaces,
+		selector:   labels.NewSelector().Add(common...),
+	}
+}
+
+// targetWatch is the running watch of a resource and the reapers using it.
+type targetWatch struct {
+	scope watchScope
+	// informers by namespace, or a single one under metav1.NamespaceAll
+	informers map[string]cache.SharedIndexInformer
+	stopCh    chan struct{}
+	reapers   sets.Set[string]
+	started   time.Time
+}
+
+// hasSynced reports whether every informer of the watch has synced.
+func (t *targetWatch) hasSynced() bool {
+	for _, informer := range t.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// informerFor returns the informer watching the namespace, or nil.
+func (t *targetWatch) informerFor(namespace string) cache.SharedIndexInformer {
+	if informer, ok := t.informers[metav1.NamespaceAll]; ok {
+		return informer
+	}
+	return t.informers[namespace]
+}
+
+// watchInfo describes an active watch.
+type watchInfo struct {
+	Resource      string    `json:"resource"`
+	Namespaces    []string  `json:"namespaces,omitempty"`
+	LabelSelector string    `json:"labelSelector,omitempty"`
+	Reapers       []string  `json:"reapers"`
+	Synced        bool      `json:"synced"`
+	Started       time.Time `json:"started"`
+	Objects       int       `json:"objects"`
+}
+
+func newTargetWatches(newInformer func(schema.GroupVersionResource, string, labels.Selector) cache.SharedIndexInformer) *targetWatches {
+	return &targetWatches{
+		watches:     make(map[schema.GroupVersionResource]*targetWatch),
+		held:        make(map[string]map[schema.GroupVersionResource][]targetScope),
+		newInformer: newInformer,
+	}
+}
+
+// set makes the reaper hold references to exactly the given resources, for
+// targets with the given scopes. It returns the resources whose watches
+// were started, or restarted with a new scope, and stopped as a result.
+func (w *targetWatches) set(reaperName string, scopes map[schema.GroupVersionResource][]targetScope) (started, stopped []schema.GroupVersionResource) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	affected := sets.KeySet(w.held[reaperName]).Union(sets.KeySet(scopes))
+	if len(scopes) == 0 {
+		delete(w.held, reaperName)
+	} else {
+		w.held[reaperName] = scopes
+	}
+
+	for gvr := range affected {
+		reapers := sets.New[string]()
+		var all []targetScope
+		for name, held := range w.held {
+			if s, ok := held[gvr]; ok {
+				reapers.Insert(name)
+				all = append(all, s...)
+			}
+		}
+
+		watch, running := w.watches[gvr]
+		if reapers.Len() == 0 {
+			if running {
+				w.stop(gvr)
+				stopped = append(stopped, gvr)
+			}
+			continue
+		}
+		scope := combineScopes(all)
+		if running && !watch.scope.equal(scope) {
+			w.stop(gvr)
+			running = false
+		}
+		if !running {
+			watch = w.start(gvr, scope)
+			started = append(started, gvr)
+		}
+		watch.reapers = reapers
+	}
+	return started, stopped
+}
+
+// start starts watching the resource within the scope. w.mu must be held.
+func (w *targetWatches) start(gvr schema.GroupVersionResource, scope watchScope) *targetWatch {
+	watch := &targetWatch{
+		scope:     scope,
+		informers: make(map[string]cache.SharedIndexInformer),
+		stopCh:    make(chan struct{}),
+		started:   time.Now(),
+	}
+	if scope.namespaces == nil {
+		watch.informers[metav1.NamespaceAll] = w.newInformer(gvr, metav1.NamespaceAll, scope.selector)
+	}
+	for namespace := range scope.namespaces {
+		watch.informers[namespace] = w.newInformer(gvr, namespace, scope.selector)
+	}
+	for _, informer := range watch.informers {
+		go informer.Run(watch.stopCh)
+	}
+	w.watches[gvr] = watch
+	return watch
+}
+
+// stop stops watching the resource. w.mu must be held.
+func (w *targetWatches) stop(gvr schema.GroupVersionResource) {
+	if watch, ok := w.watches[gvr]; ok {
+		close(watch.stopCh)
+		delete(w.watches, gvr)
+	}
+}
+
+// heldBy returns the scopes of the reaper's targets, by resource.
+func (w *targetWatches) heldBy(reaperName string) map[schema.GroupVersionResource][]targetScope {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	held := make(map[schema.GroupVersionResource][]targetScope, len(w.held[reaperName]))
+	for gvr, scopes := range w.held[reaperName] {
+		held[gvr] = scopes
+	}
+	return held
+}
+
+// reapers returns the names of the reapers holding references to any
+// resource.
+func (w *targetWatches) reapers() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return sets.List(sets.KeySet(w.held))
+}
+
+// reapersOf returns the names of the reapers holding references to the
+// resource.
+func (w *targetWatches) reapersOf(gvr schema.GroupVersionResource) []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if watch, ok := w.watches[gvr]; ok {
+		return sets.List(watch.reapers)
+	}
+	return nil
+}
+
+// get returns the cached object of the resource with the given namespace
+// and name. exists is false when the cache doesn't have it, including when
+// the object isn't watched at all.
+func (w *targetWatches) get(gvr schema.GroupVersionResource, namespace, name string) (obj *unstructured.Unstructured, exists bool, err error) {
+	w.mu.RLock()
+	watch, ok := w.watches[gvr]
+	w.mu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	informer := watch.informerFor(namespace)
+	if informer == nil {
+		return nil, false, nil
+	}
+
+	key := name
+	if namespace != metav1.NamespaceNone {
+		key = namespace + "/" + name
+	}
+	item, exists, err := informer.GetIndexer().GetByKey(key)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	obj, exists = item.(*unstructured.Unstructured)
+	return obj, exists, nil
+}
+
+// list returns the cached objects of the resource in the namespace, or in
+// all of them for metav1.NamespaceAll, that the selector matches. ok is
+// false when no synced watch covers them all, in which case they have to be
+// read from the API server.
+func (w *targetWatches) list(gvr schema.GroupVersionResource, namespace string, selector labels.Selector) (items []*unstructured.Unstructured, ok bool, err error) {
+	w.mu.RLock()
+	watch, watched := w.watches[gvr]
+	w.mu.RUnlock()
+	if !watched || !watch.hasSynced() || !watch.scope.covers(selector) {
+		return nil, false, nil
+	}
+	informer := watch.informerFor(namespace)
+	if informer == nil {
+		return nil, false, nil
+	}
+
+	err = cache.ListAllByNamespace(informer.GetIndexer(), namespace, selector, func(obj interface{}) {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			items = append(items, u)
+		}
+	})
+	return items, true, err
+}
+
+// informerCounts counts the running informers by resource.
+func (w *targetWatches) informerCounts() map[schema.GroupVersionResource]int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	counts := make(map[schema.GroupVersionResource]int, len(w.watches))
+	for gvr, watch := range w.watches {
+		counts[gvr] = len(watch.informers)
+	}
+	return counts
+}
+
+// stopAll stops every watch.
+func (w *targetWatches) stopAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for gvr := range w.watches {
+		w.stop(gvr)
+	}
+	w.held = make(map[string]map[schema.GroupVersionResource][]targetScope)
+}
+
+// describe describes the active watches, sorted by resource.
+func (w *targetWatches) describe() []watchInfo {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	infos := make([]watchInfo, 0, len(w.watches))
+	for gvr, watch := range w.watches {
+		info := watchInfo{
+			Resource:      gvr.String(),
+			Namespaces:    sets.List(watch.scope.namespaces),
+			LabelSelector: watch.scope.selector.String(),
+			Reapers:       sets.List(watch.reapers),
+			Synced:        watch.hasSynced(),
+			Started:       watch.started,
+		}
+		for _, informer := range watch.informers {
+			info.Objects += len(informer.GetStore().ListKeys())
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Resource < infos[j].Resource
+	})
+	return infos
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ttlreaper
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func mustParseSelector(t *testing.T, selector string) labels.Selector {
+	t.Helper()
+	s, err := labels.Parse(selector)
+	if err != nil {
+		t.Fatalf("labels.Parse(%q) = %v", selector, err)
+	}
+	return s
+}
+
+func TestCombineScopes(t *testing.T) {
+	tests := []struct {
+		name           string
+		scopes         map[string]string
+		wantNamespaces sets.Set[string]
+		wantSelector   string
+	}{{
+		name:           "single target",
+		scopes:         map[string]string{"ci": "app=build,tier!=cache"},
+		wantNamespaces: sets.New("ci"),
+		wantSelector:   "app=build,tier!=cache",
+	}, {
+		name:           "shared requirements",
+		scopes:         map[string]string{"ci": "app=build,tier=web", "cd": "app=build"},
+		wantNamespaces: sets.New("cd", "ci"),
+		wantSelector:   "app=build",
+	}, {
+		name:           "nothing shared",
+		scopes:         map[string]string{"ci": "app=build", "cd": "app=deploy"},
+		wantNamespaces: sets.New("cd", "ci"),
+		wantSelector:   "",
+	}, {
+		name:         "all namespaces",
+		scopes:       map[string]string{"ci": "app=build", metav1.NamespaceAll: "app=build"},
+		wantSelector: "app=build",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var scopes []targetScope
+			for namespace, selector := range test.scopes {
+				scopes = append(scopes, targetScope{namespace: namespace, selector: mustParseSelector(t, selector)})
+			}
+			got := combineScopes(scopes)
+			if (got.namespaces == nil) != (test.wantNamespaces == nil) || !got.namespaces.Equal(test.wantNamespaces) {
+				t.Errorf("namespaces = %v, want %v", sets.List(got.namespaces), sets.List(test.wantNamespaces))
+			}
+			if got.selector.String() != test.wantSelector {
+				t.Errorf("selector = %q, want %q", got.selector, test.wantSelector)
+			}
+		})
+	}
+}
+
+func TestWatchScopeCovers(t *testing.T) {
+	tests := []struct {
+		name     string
+		watch    string
+		selector string
+		want     bool
+	}{{
+		name:     "unfiltered watch",
+		watch:    "",
+		selector: "app=build",
+		want:     true,
+	}, {
+		name:     "same selector",
+		watch:    "app=build",
+		selector: "app=build",
+		want:     true,
+	}, {
+		name:     "narrower selector",
+		watch:    "app=build",
+		selector: "app=build,tier=web",
+		want:     true,
+	}, {
+		name:     "broader selector",
+		watch:    "app=build,tier=web",
+		selector: "app=build",
+		want:     false,
+	}, {
+		name:     "different selector",
+		watch:    "app=build",
+		selector: "app=deploy",
+		want:     false,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scope := watchScope{selector: mustParseSelector(t, test.watch)}
+			if got := scope.covers(mustParseSelector(t, test.selector)); got != test.want {
+				t.Errorf("covers(%q) = %v, want %v", test.selector, got, test.want)
+			}
+		})
+	}
+}